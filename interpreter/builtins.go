@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"../nodes"
+	"../location"
+)
+
+// valueType is a set of the types a builtin parameter accepts.
+type valueType uint
+
+const (
+	intType valueType = 1 << iota
+	floatType
+	stringType
+	boolType
+	arrayType
+	channelType
+	mapType
+	// The empty value, e.g. a JSON null
+	nullType
+
+	numberType = intType | floatType
+	anyType = ^valueType(0)
+)
+
+var valueTypeNames []string = []string{"int", "float", "string", "bool", "array", "channel", "map", "null"}
+
+func (t valueType) String() string {
+	if t == anyType {
+		return "any"
+	}
+
+	names := make([]string, 0)
+	for n, name := range valueTypeNames {
+		if t & (1 << uint(n)) != 0 {
+			names = append(names, name)
+		}
+	}
+
+	return strings.Join(names, "|")
+}
+
+func typeOf(v nodes.Expression) valueType {
+	switch v.(type) {
+	case *nodes.IntLiteral:
+		return intType
+	case *nodes.FloatLiteral:
+		return floatType
+	case *nodes.StringLiteral:
+		return stringType
+	case *nodes.BoolLiteral:
+		return boolType
+	case *nodes.ArrayLiteral:
+		return arrayType
+	case *channel:
+		return channelType
+	case *nodes.MapLiteral:
+		return mapType
+	case *nodes.Void:
+		return nullType
+	default:
+		return 0
+	}
+}
+
+type builtin struct {
+	name string
+	parameters []valueType
+	// The last parameter may be repeated any number of times, including none.
+	variadic bool
+	// Called with arguments already checked against parameters.
+	implementation func(i *interpreter, call *nodes.Call, args []nodes.Expression) nodes.Expression
+	// Optional, checks a call before the program runs, e.g. a literal
+	// argument, panicking if it can't succeed.
+	check func(call *nodes.Call)
+}
+
+func (b builtin) String() string {
+	parameters := make([]string, len(b.parameters))
+	for n, p := range b.parameters {
+		parameters[n] = p.String()
+	}
+
+	if b.variadic {
+		parameters[len(parameters)-1] += "..."
+	}
+
+	return fmt.Sprintf("%s(%s)", b.name, strings.Join(parameters, ", "))
+}
+
+var builtins map[string] builtin = map[string] builtin {}
+
+// registerBuiltins adds builtins to the registry, it should be called from
+// the init function of the file implementing them.
+func registerBuiltins(bs ...builtin) {
+	for _, b := range bs {
+		if _, exists := builtins[b.name]; exists {
+			panic(fmt.Sprintf("Builtin %q registered twice", b.name))
+		}
+
+		builtins[b.name] = b
+	}
+}
+
+// Constants predeclared in every program, a variable of the same name
+// shadows them.
+var builtinConstants map[string] nodes.Expression = map[string] nodes.Expression {}
+
+func registerConstant(name string, value nodes.Expression) {
+	if _, exists := builtinConstants[name]; exists {
+		panic(fmt.Sprintf("Builtin constant %q registered twice", name))
+	}
+
+	builtinConstants[name] = value
+}
+
+// constantAt returns a copy of the constant value v located at l.
+func constantAt(v nodes.Expression, l location.Location) nodes.Expression {
+	switch v := v.(type) {
+	case *nodes.IntLiteral:
+		return &nodes.IntLiteral{Value: v.Value, Location: l}
+	case *nodes.FloatLiteral:
+		return &nodes.FloatLiteral{Value: v.Value, Location: l}
+	case *nodes.StringLiteral:
+		return &nodes.StringLiteral{Value: v.Value, Location: l}
+	case *nodes.BoolLiteral:
+		return &nodes.BoolLiteral{Value: v.Value, Location: l}
+	default:
+		panic(fmt.Sprintf("Unsupported type of constant at %s", l))
+	}
+}
+
+func printBuiltins() {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Println(builtins[name])
+	}
+
+	names = names[:0]
+	for name := range builtinConstants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value, _ := formatValue(builtinConstants[name])
+		fmt.Printf("%s = %s\n", name, value)
+	}
+}
+
+// lookupBuiltin returns the builtin called by e.
+func (i *interpreter) lookupBuiltin(e *nodes.Call) builtin {
+	switch function := e.Function.(type) {
+	case *nodes.Identifier:
+		b, exists := builtins[function.Name]
+		if !exists {
+			panic("Sorry Chief, still working on non built-in functions")
+		}
+
+		return b
+	default:
+		panic(fmt.Sprintf("Cannot use token at %s as function in Call", e.Location))
+	}
+}
+
+// callBuiltin evaluates the arguments of a call to b and runs it.
+func (i *interpreter) callBuiltin(b builtin, e *nodes.Call) nodes.Expression {
+	return b.implementation(i, e, i.builtinArguments(b, e))
+}
+
+// builtinArguments evaluates the arguments of a call to b and checks them
+// against its parameters.
+func (i *interpreter) builtinArguments(b builtin, e *nodes.Call) []nodes.Expression {
+	required := len(b.parameters)
+	if b.variadic {
+		required--
+	}
+
+	if len(e.Arguments) > len(b.parameters) && !b.variadic {
+		panic(fmt.Sprintf("Too many arguments in call to %s at %s, expected %s", b.name, e.Location, b))
+	} else if len(e.Arguments) < required {
+		panic(fmt.Sprintf("Too few arguments in call to %s at %s, expected %s", b.name, e.Location, b))
+	}
+
+	args := make([]nodes.Expression, len(e.Arguments))
+	for n, a := range e.Arguments {
+		args[n] = i.interpretExpression(a)
+
+		parameter := b.parameters[len(b.parameters)-1]
+		if n < len(b.parameters) {
+			parameter = b.parameters[n]
+		}
+
+		if typeOf(args[n]) & parameter == 0 {
+			panic(fmt.Sprintf("Cannot use token at %s as type %s in call to %s", args[n].GetLocation(), parameter, b.name))
+		}
+	}
+
+	return args
+}
+
+func init() {
+	registerBuiltins(
+		builtin{"println", []valueType{stringType}, false, builtinPrintln, nil},
+		builtin{"string", []valueType{anyType}, false, builtinString, nil},
+	)
+}
+
+func builtinPrintln(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	fmt.Println(asString(args[0]))
+
+	return &nodes.Void {
+		Location: e.Location,
+	}
+}
+
+func builtinString(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	s, ok := formatValue(args[0])
+	if !ok {
+		panic(fmt.Sprintf("Invalid argument in call to string at %s", args[0].GetLocation()))
+	}
+
+	return &nodes.StringLiteral {
+		Value: s,
+		Location: e.Location,
+	}
+}
+
+// Accessors for arguments that have already been type checked.
+
+func asString(v nodes.Expression) string {
+	return v.(*nodes.StringLiteral).Value
+}
+
+func asInt(v nodes.Expression) int {
+	return v.(*nodes.IntLiteral).Value
+}
+
+// asFloat converts an int or float argument to a float.
+func asFloat(v nodes.Expression) float64 {
+	if n, ok := v.(*nodes.IntLiteral); ok {
+		return float64(n.Value)
+	}
+
+	return v.(*nodes.FloatLiteral).Value
+}
+
+func asArray(v nodes.Expression) []nodes.Expression {
+	return v.(*nodes.ArrayLiteral).Elements
+}
+
+func asMap(v nodes.Expression) map[string] nodes.Expression {
+	return v.(*nodes.MapLiteral).Values
+}
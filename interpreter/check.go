@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+
+	"../nodes"
+)
+
+// Static checks run over the whole program before it is interpreted.
+
+func checkProgram(p *nodes.Program) {
+	for _, s := range p.Statements {
+		if t, ok := s.(*nodes.Test); ok {
+			checkStatement(t.Body)
+			continue
+		}
+
+		checkStatement(s)
+	}
+}
+
+func checkStatement(s nodes.Statement) {
+	switch s := s.(type) {
+	case *nodes.If:
+		checkExpression(s.Condition)
+		checkStatement(s.Primary)
+		if s.Alternative != nil {
+			checkStatement(s.Alternative)
+		}
+	case *nodes.For:
+		checkStatement(s.PreStatement)
+		checkExpression(s.Condition)
+		checkStatement(s.PostStatement)
+		checkStatement(s.Loop)
+	case *nodes.Assignment:
+		checkExpression(s.Place)
+		checkExpression(s.Value)
+	case *nodes.Const:
+		checkExpression(s.Value)
+	case *nodes.Scope:
+		for _, statement := range s.Statements {
+			checkStatement(statement)
+		}
+	case *nodes.Import:
+		if s.Body != nil {
+			checkProgram(s.Body)
+		}
+	case *nodes.Test:
+		panic(fmt.Sprintf("Test %q at %s is not at the top level of a file", s.Name, s.Location))
+	case *nodes.Go:
+		checkExpression(s.Call)
+	case *nodes.Select:
+		for _, c := range s.Cases {
+			if c.Place != nil {
+				checkExpression(c.Place)
+			}
+			checkExpression(c.Call)
+			checkStatement(c.Body)
+		}
+		if s.Default != nil {
+			checkStatement(s.Default)
+		}
+	case *nodes.ExpressionStatement:
+		checkExpression(s.Expression)
+	}
+}
+
+func checkExpression(e nodes.Expression) {
+	switch e := e.(type) {
+	case *nodes.Call:
+		if function, ok := e.Function.(*nodes.Identifier); ok {
+			if b, exists := builtins[function.Name]; exists && b.check != nil {
+				b.check(e)
+			}
+		}
+
+		checkExpression(e.Function)
+		for _, a := range e.Arguments {
+			checkExpression(a)
+		}
+	case *nodes.Index:
+		checkExpression(e.Structure)
+		checkExpression(e.Index)
+	case *nodes.Operator:
+		checkExpression(e.Left)
+		checkExpression(e.Right)
+	case *nodes.UnaryOperator:
+		checkExpression(e.Operand)
+	case *nodes.Member:
+		checkExpression(e.Structure)
+	case *nodes.Interpolation:
+		for _, p := range e.Parts {
+			checkExpression(p)
+		}
+	case *nodes.ArrayLiteral:
+		for _, element := range e.Elements {
+			checkExpression(element)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+
+	"../nodes"
+	"../location"
+)
+
+// Constant folding. Before the program runs the value of every const is
+// computed from literals and other constants, then substituted for each use
+// of its name.
+
+type constantFolder struct {
+	// Names declared in each scope, innermost last, following the scopes
+	// the interpreter creates.
+	scopes []map[string] declaration
+}
+
+// declaration is where a name was first declared in a scope, and the const
+// declaring it, nil for variables.
+type declaration struct {
+	constant *nodes.Const
+	location.Location
+}
+
+func foldConstants(p *nodes.Program) {
+	f := &constantFolder {
+		scopes: make([]map[string] declaration, 0),
+	}
+
+	f.newScope()
+	for n, s := range p.Statements {
+		p.Statements[n] = f.foldStatement(s)
+	}
+}
+
+func (f *constantFolder) newScope() {
+	f.scopes = append(f.scopes, make(map[string] declaration))
+}
+
+func (f *constantFolder) deleteScope() {
+	f.scopes = f.scopes[:len(f.scopes)-1]
+}
+
+func (f *constantFolder) lookup(name string) (*nodes.Const, bool) {
+	for n := len(f.scopes)-1; n >= 0; n-- {
+		if d, exists := f.scopes[n][name]; exists {
+			return d.constant, true
+		}
+	}
+
+	return nil, false
+}
+
+func (f *constantFolder) foldStatement(s nodes.Statement) nodes.Statement {
+	switch s := s.(type) {
+	case *nodes.If:
+		f.newScope()
+		s.Condition = f.foldExpression(s.Condition)
+		s.Primary = f.foldStatement(s.Primary)
+		if s.Alternative != nil {
+			s.Alternative = f.foldStatement(s.Alternative)
+		}
+		f.deleteScope()
+	case *nodes.For:
+		f.newScope()
+		s.PreStatement = f.foldStatement(s.PreStatement)
+		s.Condition = f.foldExpression(s.Condition)
+		s.PostStatement = f.foldStatement(s.PostStatement)
+		s.Loop = f.foldStatement(s.Loop)
+		f.deleteScope()
+	case *nodes.Assignment:
+		s.Value = f.foldExpression(s.Value)
+
+		place, ok := s.Place.(*nodes.Identifier)
+		if !ok {
+			s.Place = f.foldExpression(s.Place)
+			break
+		}
+
+		f.declareVariable(place, s.Location)
+	case *nodes.Const:
+		if d, exists := f.scopes[len(f.scopes)-1][s.Name]; exists && d.constant != nil {
+			panic(fmt.Sprintf("Constant %q redeclared at %s, previously declared at %s", s.Name, s.Location, d.Location))
+		} else if exists {
+			panic(fmt.Sprintf("Constant %q redeclared at %s, previously declared as a variable at %s", s.Name, s.Location, d.Location))
+		}
+
+		s.Value = f.foldExpression(s.Value)
+		checkConstantExpression(s, s.Value)
+		s.Value = newInterpreter(nil).interpretExpression(s.Value)
+
+		f.scopes[len(f.scopes)-1][s.Name] = declaration{s, s.Location}
+	case *nodes.Scope:
+		f.newScope()
+		for n, statement := range s.Statements {
+			s.Statements[n] = f.foldStatement(statement)
+		}
+		f.deleteScope()
+	case *nodes.Import:
+		if s.Body != nil {
+			foldConstants(s.Body)
+		}
+	case *nodes.Test:
+		f.newScope()
+		s.Body = f.foldStatement(s.Body)
+		f.deleteScope()
+	case *nodes.Go:
+		f.foldExpression(s.Call)
+	case *nodes.Select:
+		for _, c := range s.Cases {
+			f.foldExpression(c.Call)
+
+			// Like the interpreter each case has its own scope
+			f.newScope()
+			if place, ok := c.Place.(*nodes.Identifier); ok {
+				f.declareVariable(place, c.Location)
+			}
+			c.Body = f.foldStatement(c.Body)
+			f.deleteScope()
+		}
+		if s.Default != nil {
+			f.newScope()
+			s.Default = f.foldStatement(s.Default)
+			f.deleteScope()
+		}
+	case *nodes.ExpressionStatement:
+		s.Expression = f.foldExpression(s.Expression)
+	}
+
+	return s
+}
+
+// declareVariable records an assignment to place at l.
+func (f *constantFolder) declareVariable(place *nodes.Identifier, l location.Location) {
+	c, exists := f.lookup(place.Name)
+	if c != nil {
+		panic(fmt.Sprintf("Cannot assign to constant %q at %s, declared at %s", place.Name, l, c.Location))
+	} else if !exists {
+		// Like the interpreter a new variable goes in the innermost scope
+		f.scopes[len(f.scopes)-1][place.Name] = declaration{nil, l}
+	}
+}
+
+func (f *constantFolder) foldExpression(e nodes.Expression) nodes.Expression {
+	switch e := e.(type) {
+	case *nodes.Identifier:
+		c, exists := f.lookup(e.Name)
+		if c != nil {
+			return constantAt(c.Value, e.Location)
+		}
+
+		// Builtin constants are folded unless shadowed by a variable
+		if value, isBuiltin := builtinConstants[e.Name]; !exists && isBuiltin {
+			return constantAt(value, e.Location)
+		}
+	case *nodes.Call:
+		e.Function = f.foldExpression(e.Function)
+		for n, a := range e.Arguments {
+			e.Arguments[n] = f.foldExpression(a)
+		}
+	case *nodes.Index:
+		e.Structure = f.foldExpression(e.Structure)
+		e.Index = f.foldExpression(e.Index)
+	case *nodes.Operator:
+		e.Left = f.foldExpression(e.Left)
+		e.Right = f.foldExpression(e.Right)
+	case *nodes.UnaryOperator:
+		e.Operand = f.foldExpression(e.Operand)
+	case *nodes.Interpolation:
+		for n, p := range e.Parts {
+			e.Parts[n] = f.foldExpression(p)
+		}
+	case *nodes.ArrayLiteral:
+		for n, element := range e.Elements {
+			e.Elements[n] = f.foldExpression(element)
+		}
+	}
+
+	return e
+}
+
+// checkConstantExpression reports an error if the folded value of c can't be
+// computed at compile time, i.e. it contains anything but literals and
+// operators.
+func checkConstantExpression(c *nodes.Const, e nodes.Expression) {
+	switch e := e.(type) {
+	case *nodes.IntLiteral, *nodes.FloatLiteral, *nodes.StringLiteral, *nodes.BoolLiteral:
+	case *nodes.Operator:
+		checkConstantExpression(c, e.Left)
+		checkConstantExpression(c, e.Right)
+	case *nodes.UnaryOperator:
+		checkConstantExpression(c, e.Operand)
+	case *nodes.Interpolation:
+		for _, p := range e.Parts {
+			checkConstantExpression(c, p)
+		}
+	default:
+		panic(fmt.Sprintf("Value of constant %q is not computable at compile time, found non constant expression at %s", c.Name, e.GetLocation()))
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"../nodes"
+)
+
+// printf style formatting. A directive is written as
+// %[flags][width][.precision]verb where flags are any of "-+ 0" and verb is
+// one of the keys of formatVerbs, "%%" is a literal percent sign.
+
+var formatVerbs map[byte] valueType = map[byte] valueType {
+	'd': intType,
+	'x': intType,
+	'f': numberType,
+	'e': numberType,
+	'g': numberType,
+	's': stringType,
+	'q': stringType,
+	't': boolType,
+	'v': anyType,
+}
+
+type formatDirective struct {
+	// Text preceding the directive
+	literal string
+	// The whole directive, e.g. "%-8.3f"
+	text string
+	verb byte
+}
+
+func init() {
+	registerBuiltins(
+		builtin{"format", []valueType{stringType, anyType}, true, builtinFormat, checkFormatCall},
+		builtin{"printf", []valueType{stringType, anyType}, true, builtinPrintf, checkFormatCall},
+	)
+}
+
+// parseFormat splits a format string into directives. Any text after the
+// last directive is returned as the literal of a directive with no verb.
+func parseFormat(format string) ([]formatDirective, error) {
+	directives := make([]formatDirective, 0)
+	var literal strings.Builder
+
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			literal.WriteByte(format[i])
+			continue
+		}
+
+		start := i
+		i++
+
+		if i < len(format) && format[i] == '%' {
+			literal.WriteByte('%')
+			continue
+		}
+
+		for i < len(format) && strings.IndexByte("-+ 0", format[i]) >= 0 {
+			i++
+		}
+		for i < len(format) && '0' <= format[i] && format[i] <= '9' {
+			i++
+		}
+		if i < len(format) && format[i] == '.' {
+			i++
+			for i < len(format) && '0' <= format[i] && format[i] <= '9' {
+				i++
+			}
+		}
+
+		if i >= len(format) {
+			return nil, fmt.Errorf("unterminated directive %q", format[start:])
+		}
+		if _, exists := formatVerbs[format[i]]; !exists {
+			return nil, fmt.Errorf("unknown verb %q in directive %q", format[i], format[start:i+1])
+		}
+
+		directives = append(directives, formatDirective {
+			literal: literal.String(),
+			text: format[start:i+1],
+			verb: format[i],
+		})
+		literal.Reset()
+	}
+
+	return append(directives, formatDirective{literal: literal.String()}), nil
+}
+
+// format formats args according to the format string of the call e.
+func format(e *nodes.Call, args []nodes.Expression) string {
+	directives, err := parseFormat(asString(args[0]))
+	if err != nil {
+		panic(fmt.Sprintf("Invalid format string at %s: %s", args[0].GetLocation(), err))
+	}
+
+	values := args[1:]
+	if len(values) > len(directives)-1 {
+		panic(fmt.Sprintf("Too many arguments for format string at %s, expected %d", e.Location, len(directives)-1))
+	}
+
+	var b strings.Builder
+
+	for n, d := range directives {
+		b.WriteString(d.literal)
+
+		if d.verb == 0 {
+			break
+		}
+		if n >= len(values) {
+			panic(fmt.Sprintf("Missing argument for directive %s at %s", d.text, e.Location))
+		}
+
+		v := values[n]
+		if typeOf(v) & formatVerbs[d.verb] == 0 {
+			panic(fmt.Sprintf("Cannot use token at %s as type %s for directive %s", v.GetLocation(), formatVerbs[d.verb], d.text))
+		}
+
+		switch d.verb {
+		case 'd', 'x':
+			b.WriteString(fmt.Sprintf(d.text, asInt(v)))
+		case 'f', 'e', 'g':
+			b.WriteString(fmt.Sprintf(d.text, asFloat(v)))
+		case 's', 'q':
+			b.WriteString(fmt.Sprintf(d.text, asString(v)))
+		case 't':
+			b.WriteString(fmt.Sprintf(d.text, v.(*nodes.BoolLiteral).Value))
+		case 'v':
+			s, ok := formatValue(v)
+			if !ok {
+				panic(fmt.Sprintf("Cannot format value at %s", v.GetLocation()))
+			}
+			b.WriteString(fmt.Sprintf(d.text[:len(d.text)-1]+"s", s))
+		}
+	}
+
+	return b.String()
+}
+
+func builtinFormat(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	return &nodes.StringLiteral {
+		Value: format(e, args),
+		Location: e.Location,
+	}
+}
+
+func builtinPrintf(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	fmt.Print(format(e, args))
+
+	return &nodes.Void {
+		Location: e.Location,
+	}
+}
+
+// checkFormatCall statically validates a call to format or printf whose
+// format string is a literal, checking the number of arguments and the
+// types of any literal arguments.
+func checkFormatCall(e *nodes.Call) {
+	if len(e.Arguments) == 0 {
+		return
+	}
+
+	f, ok := e.Arguments[0].(*nodes.StringLiteral)
+	if !ok {
+		return
+	}
+
+	directives, err := parseFormat(f.Value)
+	if err != nil {
+		panic(fmt.Sprintf("Invalid format string at %s: %s", f.Location, err))
+	}
+
+	values := e.Arguments[1:]
+	if len(values) != len(directives)-1 {
+		panic(fmt.Sprintf("Format string at %s has %s but is given %s", f.Location,
+			plural(len(directives)-1, "directive"), plural(len(values), "argument")))
+	}
+
+	for n, v := range values {
+		if t := typeOf(v); t != 0 && t & formatVerbs[directives[n].verb] == 0 {
+			panic(fmt.Sprintf("Cannot use token at %s as type %s for directive %s", v.GetLocation(), formatVerbs[directives[n].verb], directives[n].text))
+		}
+	}
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return "1 "+word
+	}
+
+	return strconv.Itoa(n)+" "+word+"s"
+}
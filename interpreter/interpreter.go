@@ -1,23 +1,113 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
 
 	"../nodes"
 )
 
 type interpreter struct {
 	symbolTable []map[string] nodes.Expression
+	// Guards the scopes in symbolTable, imports and modules, which are
+	// shared with the interpreters of tasks started by this one
+	symbols *sync.RWMutex
+	// The task running the interpreter
+	task *task
+	// Command line arguments passed to the program
+	args []string
+	// Shared with the interpreters of tasks started by this one
+	stdin *input
+	// Absolute path of the file imported under each namespace
+	imports map[string] string
+	// Top level variables of every imported file by absolute path, shared
+	// between the interpreters of all files
+	modules map[string] map[string] nodes.Expression
+	// Set when running tests, where exit fails the test instead
+	testing bool
 }
 
-func newInterpreter() *interpreter {
+func newInterpreter(args []string) *interpreter {
 	return &interpreter {
 		symbolTable: make([]map[string] nodes.Expression, 0),
+		symbols: &sync.RWMutex{},
+		task: newMainTask(),
+		args: args,
+		stdin: &input{reader: bufio.NewReader(os.Stdin)},
+		imports: make(map[string] string),
+		modules: make(map[string] map[string] nodes.Expression),
+	}
+}
+
+// modulePath returns the absolute path of the file imported by s, which
+// modules are kept by so a file imported through several paths runs once.
+func modulePath(s *nodes.Import) string {
+	path, err := filepath.Abs(s.Path)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to import %q at %s: %s", s.Path, s.Location, err))
+	}
+
+	return path
+}
+
+// importModule runs the body of an imported file with its own symbol table,
+// once per file, and returns its top level variables.
+func (i *interpreter) importModule(s *nodes.Import) map[string] nodes.Expression {
+	path := modulePath(s)
+
+	i.symbols.RLock()
+	symbols, exists := i.modules[path]
+	i.symbols.RUnlock()
+	if exists {
+		return symbols
+	}
+
+	if s.Body == nil {
+		panic(fmt.Sprintf("Import of %q at %s has not been linked", s.Path, s.Location))
+	}
+
+	m := &interpreter {
+		symbolTable: make([]map[string] nodes.Expression, 0),
+		symbols: i.symbols,
+		task: i.task,
+		args: i.args,
+		stdin: i.stdin,
+		imports: make(map[string] string),
+		modules: i.modules,
+		testing: i.testing,
+	}
+
+	symbols = m.interpretProgram(s.Body)
+	i.symbols.Lock()
+	i.modules[path] = symbols
+	i.symbols.Unlock()
+
+	return symbols
+}
+
+// fork returns an interpreter for task t that shares the scopes of i.
+func (i *interpreter) fork(t *task) *interpreter {
+	return &interpreter {
+		symbolTable: append([]map[string] nodes.Expression{}, i.symbolTable...),
+		symbols: i.symbols,
+		task: t,
+		args: i.args,
+		stdin: i.stdin,
+		imports: i.imports,
+		modules: i.modules,
+		testing: i.testing,
 	}
 }
 
 func (i *interpreter) retrieveSymbol(s string) (nodes.Expression, bool) {
+	i.symbols.RLock()
+	defer i.symbols.RUnlock()
+
 	for _, m := range i.symbolTable {
 		e, exists := m[s]
 		if exists {
@@ -29,6 +119,9 @@ func (i *interpreter) retrieveSymbol(s string) (nodes.Expression, bool) {
 }
 
 func (i *interpreter) storeSymbol(name string, e nodes.Expression) {
+	i.symbols.Lock()
+	defer i.symbols.Unlock()
+
 	for n := range i.symbolTable {
 		_, exists := i.symbolTable[n][name]
 		if exists {
@@ -53,6 +146,60 @@ func (i *interpreter) deleteScope() {
 	}
 }
 
+// formatValue converts a value to its string representation, as done by the
+// string builtin. It reports false if the value has no such representation.
+func formatValue(v nodes.Expression) (string, bool) {
+	switch v := v.(type) {
+	case *nodes.StringLiteral:
+		return v.Value, true
+	case *nodes.IntLiteral:
+		return strconv.Itoa(v.Value), true
+	case *nodes.FloatLiteral:
+		return strconv.FormatFloat(v.Value, 'E', -1, 64), true
+	case *nodes.BoolLiteral:
+		return strconv.FormatBool(v.Value), true
+	case *nodes.Void:
+		return "null", true
+	case *nodes.ArrayLiteral:
+		elements := make([]string, len(v.Elements))
+
+		for n, e := range v.Elements {
+			if s, ok := e.(*nodes.StringLiteral); ok {
+				elements[n] = strconv.Quote(s.Value)
+				continue
+			}
+
+			s, ok := formatValue(e)
+			if !ok {
+				return "", false
+			}
+			elements[n] = s
+		}
+
+		return "["+strings.Join(elements, ", ")+"]", true
+	case *nodes.MapLiteral:
+		entries := make([]string, 0, len(v.Values))
+
+		for _, k := range v.Keys() {
+			value := v.Values[k]
+			if s, ok := value.(*nodes.StringLiteral); ok {
+				entries = append(entries, strconv.Quote(k)+": "+strconv.Quote(s.Value))
+				continue
+			}
+
+			s, ok := formatValue(value)
+			if !ok {
+				return "", false
+			}
+			entries = append(entries, strconv.Quote(k)+": "+s)
+		}
+
+		return "{"+strings.Join(entries, ", ")+"}", true
+	default:
+		return "", false
+	}
+}
+
 func (i *interpreter) interpretExpression(e nodes.Expression) nodes.Expression {
 	switch e := e.(type) {
 	case *nodes.IntLiteral:
@@ -63,64 +210,117 @@ func (i *interpreter) interpretExpression(e nodes.Expression) nodes.Expression {
 		return e
 	case *nodes.BoolLiteral:
 		return e
+	case *nodes.ArrayLiteral:
+		a := &nodes.ArrayLiteral {
+			Elements: make([]nodes.Expression, len(e.Elements)),
+			Location: e.Location,
+		}
+
+		for n, element := range e.Elements {
+			a.Elements[n] = i.interpretExpression(element)
+		}
+
+		return a
+	case *nodes.MapLiteral:
+		m := &nodes.MapLiteral {
+			Values: make(map[string] nodes.Expression, len(e.Values)),
+			Location: e.Location,
+		}
+
+		for k, v := range e.Values {
+			m.Values[k] = i.interpretExpression(v)
+		}
+
+		return m
+	case *nodes.Interpolation:
+		var b strings.Builder
+
+		for _, part := range e.Parts {
+			v := i.interpretExpression(part)
+			s, ok := formatValue(v)
+			if !ok {
+				panic(fmt.Sprintf("Cannot interpolate value at %s into string", v.GetLocation()))
+			}
+			b.WriteString(s)
+		}
+
+		return &nodes.StringLiteral {
+			Value: b.String(),
+			Location: e.Location,
+		}
 	case *nodes.Identifier:
 		val, exists := i.retrieveSymbol(e.Name)
 		if exists {
 			return val
+		} else if c, exists := builtinConstants[e.Name]; exists {
+			return constantAt(c, e.Location)
 		} else {
 			panic(fmt.Sprintf("Undeclared variable %q at %s", e.Name, e.Location))
 		}
+	case *nodes.Member:
+		namespace, ok := e.Structure.(*nodes.Identifier)
+		if !ok {
+			panic(fmt.Sprintf("Cannot access member %q of value at %s", e.Name, e.Structure.GetLocation()))
+		}
+
+		i.symbols.RLock()
+		path, imported := i.imports[namespace.Name]
+		val, exists := i.modules[path][e.Name]
+		i.symbols.RUnlock()
+
+		if !imported {
+			panic(fmt.Sprintf("Undeclared import %q at %s", namespace.Name, namespace.Location))
+		}
+
+		if !exists {
+			panic(fmt.Sprintf("Undeclared variable %q in %q at %s", e.Name, path, e.Location))
+		}
+
+		return val
 	case *nodes.Call:
-		switch function := e.Function.(type) {
-		case *nodes.Identifier:
-			switch function.Name {
-			case "println":
-				if len(e.Arguments) > 1 {
-					panic(fmt.Sprintf("Too many arguments in call to println at %s", e.Location))
-				} else if len(e.Arguments) == 0 {
-					panic(fmt.Sprintf("Too few arguments in call to println at %s", e.Location))
-				} else {
-					switch s := i.interpretExpression(e.Arguments[0]); s := s.(type) {
-					case *nodes.StringLiteral:
-						fmt.Println(s.Value)
-						return &nodes.Void {
-							Location: e.Location,
-						}
-					default:
-						panic(fmt.Sprintf("Cannot use token at %s as type string in call to println", s.GetLocation()))
-					}
-				}
-			case "string":
-				if len(e.Arguments) > 1 {
-					panic(fmt.Sprintf("Too many arguments in call to string at %s", e.Location))
-				} else if len(e.Arguments) == 0 {
-					panic(fmt.Sprintf("Too few arguments in call to string at %s", e.Location))
-				} else {
-					switch v := i.interpretExpression(e.Arguments[0]); v := v.(type) {
-					case *nodes.StringLiteral:
-						return e
-					case *nodes.IntLiteral:
-						return &nodes.StringLiteral {
-							Value: strconv.Itoa(v.Value),
-							Location: e.Location,
-						}
-					case *nodes.FloatLiteral:
-						return &nodes.StringLiteral {
-							Value: strconv.FormatFloat(float64(v.Value), 'E', -1, 32),
-							Location: e.Location,
-						}
-					default:
-						panic(fmt.Sprintf("Invalid argument in call to string at %s", v.GetLocation()))
-					}
-				}
-			default:
-				panic("Sorry Chief, still working on non built-in functions")
+		return i.callBuiltin(i.lookupBuiltin(e), e)
+	case *nodes.Index:
+		structure := i.interpretExpression(e.Structure)
+
+		if m, ok := structure.(*nodes.MapLiteral); ok {
+			key, ok := i.interpretExpression(e.Index).(*nodes.StringLiteral)
+			if !ok {
+				panic(fmt.Sprintf("Non string expression used as map key at %s", e.Index.GetLocation()))
+			}
+
+			val, exists := m.Values[key.Value]
+			if !exists {
+				panic(fmt.Sprintf("Key %q not found in map at %s", key.Value, e.Location))
+			}
+
+			return val
+		}
+
+		index, ok := i.interpretExpression(e.Index).(*nodes.IntLiteral)
+		if !ok {
+			panic(fmt.Sprintf("Non int expression used as index at %s", e.Index.GetLocation()))
+		}
+
+		switch structure := structure.(type) {
+		case *nodes.ArrayLiteral:
+			if index.Value < 0 || index.Value >= len(structure.Elements) {
+				panic(fmt.Sprintf("Index %d out of range for array of length %d at %s", index.Value, len(structure.Elements), e.Location))
+			}
+
+			return structure.Elements[index.Value]
+		case *nodes.StringLiteral:
+			runes := []rune(structure.Value)
+			if index.Value < 0 || index.Value >= len(runes) {
+				panic(fmt.Sprintf("Index %d out of range for string of length %d at %s", index.Value, len(runes), e.Location))
+			}
+
+			return &nodes.StringLiteral {
+				Value: string(runes[index.Value]),
+				Location: e.Location,
 			}
 		default:
-			panic(fmt.Sprintf("Cannot use token at %s as function in Call", e.Location))
+			panic(fmt.Sprintf("Cannot index value at %s", structure.GetLocation()))
 		}
-	case *nodes.Index:
-		panic("Sorry Chief, still working on supporting arrays")
 	case *nodes.Operator:
 		left := i.interpretExpression(e.Left)
 		right := i.interpretExpression(e.Right)
@@ -146,6 +346,10 @@ func (i *interpreter) interpretExpression(e nodes.Expression) nodes.Expression {
 						Location: e.Location,
 					}
 				case "/":
+					// Also reached when folding constants, e.g. const D = 1 / 0
+					if right.Value == 0 {
+						panic(fmt.Sprintf("Integer division by zero at %s", e.Location))
+					}
 					return &nodes.IntLiteral {
 						Value: left.Value / right.Value,
 						Location: e.Location,
@@ -293,6 +497,18 @@ func (i *interpreter) interpretExpression(e nodes.Expression) nodes.Expression {
 }
 
 
+// interpretProgram runs the statements of p in order and returns the top
+// level variables.
+func (i *interpreter) interpretProgram(p *nodes.Program) map[string] nodes.Expression {
+	i.newScope()
+
+	for _, s := range p.Statements {
+		i.interpretStatement(s)
+	}
+
+	return i.symbolTable[0]
+}
+
 func (i *interpreter) interpretStatement(s nodes.Statement) {
 	switch s := s.(type) {
 	case *nodes.If:
@@ -342,6 +558,23 @@ func (i *interpreter) interpretStatement(s nodes.Statement) {
 			i.interpretStatement(statement)
 		}
 		i.deleteScope()
+	case *nodes.Const:
+		// Uses are already folded, this only makes the constant visible
+		// to files importing this one
+		i.symbols.Lock()
+		i.symbolTable[len(i.symbolTable)-1][s.Name] = s.Value
+		i.symbols.Unlock()
+	case *nodes.Import:
+		i.importModule(s)
+		i.symbols.Lock()
+		i.imports[s.Namespace()] = modulePath(s)
+		i.symbols.Unlock()
+	case *nodes.Test:
+		// Only run by runTests
+	case *nodes.Go:
+		i.spawn(s)
+	case *nodes.Select:
+		i.interpretSelect(s)
 	case *nodes.ExpressionStatement:
 		//println("ExpressionStatement")
 		i.interpretExpression(s.Expression)
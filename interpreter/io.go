@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"../nodes"
+)
+
+// Input and output builtins. Reading from stdin only makes sense when the
+// ast is given to the interpreter as a file, otherwise stdin holds the ast.
+
+// input is stdin, which the interpreters of every task share so each read
+// holds the lock.
+type input struct {
+	sync.Mutex
+	reader *bufio.Reader
+}
+
+func init() {
+	registerBuiltins(
+		builtin{"readLine", []valueType{}, false, builtinReadLine, nil},
+		builtin{"readAll", []valueType{}, false, builtinReadAll, nil},
+		builtin{"eof", []valueType{}, false, builtinEOF, nil},
+		builtin{"readFile", []valueType{stringType}, false, builtinReadFile, nil},
+		builtin{"writeFile", []valueType{stringType, stringType}, false, builtinWriteFile, nil},
+		builtin{"appendFile", []valueType{stringType, stringType}, false, builtinAppendFile, nil},
+		builtin{"args", []valueType{}, false, builtinArgs, nil},
+		builtin{"env", []valueType{stringType}, false, builtinEnv, nil},
+		builtin{"exit", []valueType{intType}, false, builtinExit, nil},
+	)
+}
+
+// builtinReadLine reads the next line from stdin without its line ending,
+// at the end of input it returns an empty string, see eof.
+func builtinReadLine(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	i.stdin.Lock()
+	line, err := i.stdin.reader.ReadString('\n')
+	i.stdin.Unlock()
+	if err != nil && err != io.EOF {
+		panic(fmt.Sprintf("Failed to read from stdin at %s: %s", e.Location, err))
+	}
+
+	line = strings.TrimSuffix(line, "\n")
+	line = strings.TrimSuffix(line, "\r")
+
+	return &nodes.StringLiteral {
+		Value: line,
+		Location: e.Location,
+	}
+}
+
+func builtinReadAll(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	i.stdin.Lock()
+	buff, err := ioutil.ReadAll(i.stdin.reader)
+	i.stdin.Unlock()
+	if err != nil {
+		panic(fmt.Sprintf("Failed to read from stdin at %s: %s", e.Location, err))
+	}
+
+	return &nodes.StringLiteral {
+		Value: string(buff),
+		Location: e.Location,
+	}
+}
+
+func builtinEOF(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	i.stdin.Lock()
+	_, err := i.stdin.reader.Peek(1)
+	i.stdin.Unlock()
+	if err != nil && err != io.EOF {
+		panic(fmt.Sprintf("Failed to read from stdin at %s: %s", e.Location, err))
+	}
+
+	return &nodes.BoolLiteral {
+		Value: err == io.EOF,
+		Location: e.Location,
+	}
+}
+
+func builtinReadFile(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	buff, err := ioutil.ReadFile(asString(args[0]))
+	if err != nil {
+		panic(fmt.Sprintf("Failed to read file at %s: %s", e.Location, err))
+	}
+
+	return &nodes.StringLiteral {
+		Value: string(buff),
+		Location: e.Location,
+	}
+}
+
+func builtinWriteFile(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	err := ioutil.WriteFile(asString(args[0]), []byte(asString(args[1])), 0644)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to write file at %s: %s", e.Location, err))
+	}
+
+	return &nodes.Void {
+		Location: e.Location,
+	}
+}
+
+func builtinAppendFile(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	f, err := os.OpenFile(asString(args[0]), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err == nil {
+		_, err = f.WriteString(asString(args[1]))
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		panic(fmt.Sprintf("Failed to append to file at %s: %s", e.Location, err))
+	}
+
+	return &nodes.Void {
+		Location: e.Location,
+	}
+}
+
+func builtinArgs(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	return stringArray(i.args, e)
+}
+
+// builtinEnv returns the value of an environment variable, or an empty
+// string if it isn't set.
+func builtinEnv(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	return &nodes.StringLiteral {
+		Value: os.Getenv(asString(args[0])),
+		Location: e.Location,
+	}
+}
+
+func builtinExit(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	// Would end every other test too
+	if i.testing {
+		panic(fmt.Sprintf("exit(%d) called while testing at %s", asInt(args[0]), e.Location))
+	}
+
+	os.Exit(asInt(args[0]))
+
+	return nil
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"../nodes"
+)
+
+// JSON builtins. Numbers without a fraction or exponent that fit in an int
+// are decoded as ints, all others as floats. Encoding floats always keeps a
+// fraction or exponent so they decode as floats again. null is decoded as an
+// empty value of type null, which is encoded and formatted as null.
+
+func init() {
+	registerBuiltins(
+		builtin{"jsonParse", []valueType{stringType}, false, builtinJSONParse, nil},
+		builtin{"jsonStringify", []valueType{anyType, intType | stringType}, false, builtinJSONStringify, nil},
+	)
+}
+
+func builtinJSONParse(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	input := asString(args[0])
+
+	decoder := json.NewDecoder(strings.NewReader(input))
+	decoder.UseNumber()
+
+	var v interface{}
+	if err := decoder.Decode(&v); err == io.EOF {
+		panic(fmt.Sprintf("Invalid JSON in call to jsonParse at %s: empty input", e.Location))
+	} else if err != nil {
+		offset := len(input)
+		if syntaxErr, ok := err.(*json.SyntaxError); ok {
+			// The offset is just past the byte that caused the error
+			offset = int(syntaxErr.Offset) - 1
+		} else if err != io.ErrUnexpectedEOF {
+			offset = int(decoder.InputOffset())
+		}
+
+		panic(fmt.Sprintf("Invalid JSON in call to jsonParse at %s, %s: %s", e.Location, jsonPosition(input, offset), err))
+	}
+
+	if _, err := decoder.Token(); err != io.EOF {
+		offset := int(decoder.InputOffset())
+		for offset < len(input) && strings.ContainsRune(" \t\r\n", rune(input[offset])) {
+			offset++
+		}
+
+		panic(fmt.Sprintf("Invalid JSON in call to jsonParse at %s, %s: data after top-level value", e.Location, jsonPosition(input, offset)))
+	}
+
+	return fromJSON(v, e)
+}
+
+// jsonPosition describes the position of the byte at offset in input as a
+// line and a column counted in runes.
+func jsonPosition(input string, offset int) string {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > len(input) {
+		offset = len(input)
+	}
+
+	line := strings.Count(input[:offset], "\n") + 1
+	column := utf8.RuneCountInString(input[strings.LastIndex(input[:offset], "\n")+1:offset]) + 1
+
+	return fmt.Sprintf("line %d column %d of input", line, column)
+}
+
+// fromJSON converts a value decoded by encoding/json to a value located at
+// the call e.
+func fromJSON(v interface{}, e *nodes.Call) nodes.Expression {
+	switch v := v.(type) {
+	case nil:
+		return &nodes.Void {
+			Location: e.Location,
+		}
+	case bool:
+		return &nodes.BoolLiteral {
+			Value: v,
+			Location: e.Location,
+		}
+	case string:
+		return &nodes.StringLiteral {
+			Value: v,
+			Location: e.Location,
+		}
+	case json.Number:
+		if !strings.ContainsAny(string(v), ".eE") {
+			n, err := strconv.Atoi(string(v))
+			if err != nil {
+				panic(fmt.Sprintf("Integer %s out of range in call to jsonParse at %s, write it with a fraction to decode it as a float", v, e.Location))
+			}
+
+			return &nodes.IntLiteral {
+				Value: n,
+				Location: e.Location,
+			}
+		}
+
+		f, err := strconv.ParseFloat(string(v), 64)
+		if err != nil {
+			panic(fmt.Sprintf("Number %s out of range in call to jsonParse at %s", v, e.Location))
+		}
+
+		return &nodes.FloatLiteral {
+			Value: f,
+			Location: e.Location,
+		}
+	case []interface{}:
+		a := &nodes.ArrayLiteral {
+			Elements: make([]nodes.Expression, len(v)),
+			Location: e.Location,
+		}
+
+		for n, element := range v {
+			a.Elements[n] = fromJSON(element, e)
+		}
+
+		return a
+	case map[string] interface{}:
+		m := &nodes.MapLiteral {
+			Values: make(map[string] nodes.Expression, len(v)),
+			Location: e.Location,
+		}
+
+		for k, value := range v {
+			m.Values[k] = fromJSON(value, e)
+		}
+
+		return m
+	default:
+		panic(fmt.Sprintf("Unexpected JSON value %v in call to jsonParse at %s", v, e.Location))
+	}
+}
+
+// builtinJSONStringify encodes a value, indenting nested values by the given
+// string or number of spaces. No indentation puts everything on one line.
+func builtinJSONStringify(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	var indent string
+	if typeOf(args[1]) == intType {
+		if asInt(args[1]) < 0 {
+			panic(fmt.Sprintf("Negative indentation in call to jsonStringify at %s", args[1].GetLocation()))
+		}
+		indent = strings.Repeat(" ", asInt(args[1]))
+	} else {
+		indent = asString(args[1])
+	}
+
+	var b strings.Builder
+	writeJSON(&b, args[0], indent, "\n")
+
+	return &nodes.StringLiteral {
+		Value: b.String(),
+		Location: e.Location,
+	}
+}
+
+// writeJSON writes the encoding of v to b, where newline starts a new line at
+// the current depth.
+func writeJSON(b *strings.Builder, v nodes.Expression, indent string, newline string) {
+	switch v := v.(type) {
+	case *nodes.Void:
+		b.WriteString("null")
+	case *nodes.BoolLiteral:
+		b.WriteString(strconv.FormatBool(v.Value))
+	case *nodes.IntLiteral:
+		b.WriteString(strconv.Itoa(v.Value))
+	case *nodes.FloatLiteral:
+		if math.IsInf(v.Value, 0) || math.IsNaN(v.Value) {
+			panic(fmt.Sprintf("Cannot encode %v at %s as JSON", v.Value, v.Location))
+		}
+
+		f := strconv.FormatFloat(v.Value, 'g', -1, 64)
+		if !strings.ContainsAny(f, ".e") {
+			f += ".0"
+		}
+		b.WriteString(f)
+	case *nodes.StringLiteral:
+		b.WriteString(jsonString(v.Value))
+	case *nodes.ArrayLiteral:
+		if len(v.Elements) == 0 {
+			b.WriteString("[]")
+			return
+		}
+
+		b.WriteString("[")
+		for n, element := range v.Elements {
+			if n > 0 {
+				b.WriteString(",")
+			}
+			if indent != "" {
+				b.WriteString(newline+indent)
+			}
+			writeJSON(b, element, indent, newline+indent)
+		}
+		if indent != "" {
+			b.WriteString(newline)
+		}
+		b.WriteString("]")
+	case *nodes.MapLiteral:
+		if len(v.Values) == 0 {
+			b.WriteString("{}")
+			return
+		}
+
+		b.WriteString("{")
+		for n, k := range v.Keys() {
+			if n > 0 {
+				b.WriteString(",")
+			}
+			if indent != "" {
+				b.WriteString(newline+indent)
+			}
+			b.WriteString(jsonString(k)+":")
+			if indent != "" {
+				b.WriteString(" ")
+			}
+			writeJSON(b, v.Values[k], indent, newline+indent)
+		}
+		if indent != "" {
+			b.WriteString(newline)
+		}
+		b.WriteString("}")
+	default:
+		panic(fmt.Sprintf("Cannot encode token at %s as JSON", v.GetLocation()))
+	}
+}
+
+func jsonString(s string) string {
+	var b bytes.Buffer
+
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
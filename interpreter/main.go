@@ -2,26 +2,79 @@ package main
 
 import (
 	"bufio"
+	"flag"
+	"fmt"
+	"io"
 	"os"
+	"time"
 
 	"../nodes"
 )
 
 func main() {
-	if len(os.Args) > 1 {
+	listBuiltins := flag.Bool("list-builtins", false, "print the signature of every builtin function and exit")
+	test := flag.Bool("test", false, "run the test blocks of the program instead of the program, exiting with status 1 if any fail")
+	seed := flag.Int64("seed", 0, "seed for the random builtins, to make runs reproducible (default based on the current time)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] [ast file [script arguments...]]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Reads the ast from stdin if no file is given.\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *listBuiltins {
+		printBuiltins()
+		return
+	}
+
+	seedRandom(time.Now().UnixNano())
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			seedRandom(*seed)
+		}
+	})
+
+	var input io.Reader
+	args := make([]string, 0)
+
+	if flag.NArg() > 0 {
+		f, err := os.Open(flag.Arg(0))
+		if err != nil {
+			panic(err)
+		}
+		defer f.Close()
 
+		input = f
+		args = flag.Args()[1:]
 	} else {
-		stdin := bufio.NewScanner(os.Stdin)
+		input = os.Stdin
+	}
 
-		if stdin.Scan() {
-			s, err := nodes.StatementFromScanner(stdin)
-			if err != nil {
-				panic(err)
-			}
+	scanner := bufio.NewScanner(input)
+
+	if scanner.Scan() {
+		p, err := nodes.ProgramFromScanner(scanner)
+		if err != nil {
+			panic(err)
+		}
 
-			i := newInterpreter()
+		foldConstants(p)
+		checkProgram(p)
 
-			i.interpretStatement(s)
+		if *test {
+			if !runTests(p, args) {
+				os.Exit(1)
+			}
+			return
 		}
+
+		i := newInterpreter(args)
+
+		i.interpretProgram(p)
+	} else {
+		// Even an empty program has an ast, so an earlier stage has failed
+		// and already said why
+		fmt.Fprintln(os.Stderr, "No program to run, the ast is empty")
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"../nodes"
+)
+
+// Map builtins. Maps are indexed by string keys like arrays are by ints,
+// e.g. m["key"].
+
+func init() {
+	registerBuiltins(
+		builtin{"keys", []valueType{mapType}, false, builtinKeys, nil},
+		builtin{"hasKey", []valueType{mapType, stringType}, false, builtinHasKey, nil},
+	)
+}
+
+// builtinKeys returns the keys of a map in sorted order.
+func builtinKeys(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	return stringArray(args[0].(*nodes.MapLiteral).Keys(), e)
+}
+
+func builtinHasKey(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	_, exists := asMap(args[0])[asString(args[1])]
+
+	return &nodes.BoolLiteral {
+		Value: exists,
+		Location: e.Location,
+	}
+}
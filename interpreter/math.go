@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"../nodes"
+)
+
+// Math builtins. They accept ints and floats alike and always return a
+// float.
+
+func init() {
+	registerBuiltins(
+		mathBuiltin("sqrt", math.Sqrt),
+		builtin{"pow", []valueType{numberType, numberType}, false, builtinPow, nil},
+		mathBuiltin("abs", math.Abs),
+		mathBuiltin("floor", math.Floor),
+		mathBuiltin("ceil", math.Ceil),
+		mathBuiltin("round", math.Round),
+		builtin{"min", []valueType{numberType, numberType}, true, builtinMin, nil},
+		builtin{"max", []valueType{numberType, numberType}, true, builtinMax, nil},
+		mathBuiltin("sin", math.Sin),
+		mathBuiltin("cos", math.Cos),
+		mathBuiltin("tan", math.Tan),
+		mathBuiltin("asin", math.Asin),
+		mathBuiltin("acos", math.Acos),
+		mathBuiltin("atan", math.Atan),
+		builtin{"atan2", []valueType{numberType, numberType}, false, builtinAtan2, nil},
+		mathBuiltin("exp", math.Exp),
+		mathBuiltin("log", math.Log),
+		mathBuiltin("log2", math.Log2),
+		mathBuiltin("log10", math.Log10),
+	)
+
+	registerConstant("PI", &nodes.FloatLiteral{Value: math.Pi})
+	registerConstant("E", &nodes.FloatLiteral{Value: math.E})
+}
+
+// mathBuiltin wraps a function of one float as a builtin.
+func mathBuiltin(name string, f func(float64) float64) builtin {
+	return builtin{name, []valueType{numberType}, false, func(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+		return floatResult(name, e, args, f(asFloat(args[0])))
+	}, nil}
+}
+
+// floatResult returns result as a value, reporting a domain error if the
+// finite arguments of the call produced a NaN and a range error if they
+// produced an infinite result, e.g. an overflow.
+func floatResult(name string, e *nodes.Call, args []nodes.Expression, result float64) nodes.Expression {
+	if math.IsNaN(result) || math.IsInf(result, 0) {
+		finite := true
+		for _, a := range args {
+			if f := asFloat(a); math.IsNaN(f) || math.IsInf(f, 0) {
+				finite = false
+			}
+		}
+
+		if finite {
+			values := make([]string, len(args))
+			for n, a := range args {
+				values[n] = fmt.Sprint(asFloat(a))
+			}
+
+			if math.IsNaN(result) {
+				panic(fmt.Sprintf("Domain error in call to %s(%s) at %s", name, strings.Join(values, ", "), e.Location))
+			}
+			panic(fmt.Sprintf("Range error in call to %s(%s) at %s, the result overflows to %v", name, strings.Join(values, ", "), e.Location, result))
+		}
+	}
+
+	return &nodes.FloatLiteral {
+		Value: result,
+		Location: e.Location,
+	}
+}
+
+func builtinPow(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	return floatResult("pow", e, args, math.Pow(asFloat(args[0]), asFloat(args[1])))
+}
+
+func builtinAtan2(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	return floatResult("atan2", e, args, math.Atan2(asFloat(args[0]), asFloat(args[1])))
+}
+
+func builtinMin(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	result := asFloat(args[0])
+	for _, a := range args[1:] {
+		result = math.Min(result, asFloat(a))
+	}
+
+	return floatResult("min", e, args, result)
+}
+
+func builtinMax(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	result := asFloat(args[0])
+	for _, a := range args[1:] {
+		result = math.Max(result, asFloat(a))
+	}
+
+	return floatResult("max", e, args, result)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+
+	"../nodes"
+	"../location"
+)
+
+// Regular expression builtins, using the syntax of Go's regexp package.
+// Like the other string builtins they take the string first and the pattern
+// second.
+
+func init() {
+	registerBuiltins(
+		builtin{"match", []valueType{stringType, stringType}, false, builtinMatch, checkRegexCall},
+		builtin{"find", []valueType{stringType, stringType}, false, builtinFind, checkRegexCall},
+		builtin{"findAll", []valueType{stringType, stringType}, false, builtinFindAll, checkRegexCall},
+		builtin{"captures", []valueType{stringType, stringType}, false, builtinCaptures, checkRegexCall},
+		builtin{"capturesAll", []valueType{stringType, stringType}, false, builtinCapturesAll, checkRegexCall},
+		builtin{"replaceRegex", []valueType{stringType, stringType, stringType}, false, builtinReplaceRegex, checkRegexCall},
+		builtin{"splitRegex", []valueType{stringType, stringType}, false, builtinSplitRegex, checkRegexCall},
+	)
+}
+
+// Compiled patterns by the location of the call using them, so a pattern is
+// only compiled again if the call is given a different one.
+var regexCache struct {
+	sync.Mutex
+	patterns map[location.Location] *regexp.Regexp
+}
+
+// compileRegex returns the compiled pattern argument of e.
+func compileRegex(e *nodes.Call, pattern nodes.Expression) *regexp.Regexp {
+	regexCache.Lock()
+	defer regexCache.Unlock()
+
+	if re, exists := regexCache.patterns[e.Location]; exists && re.String() == asString(pattern) {
+		return re
+	}
+
+	re, err := regexp.Compile(asString(pattern))
+	if err != nil {
+		panic(fmt.Sprintf("Invalid regular expression at %s: %s", pattern.GetLocation(), err))
+	}
+
+	if regexCache.patterns == nil {
+		regexCache.patterns = make(map[location.Location] *regexp.Regexp)
+	}
+	regexCache.patterns[e.Location] = re
+
+	return re
+}
+
+// checkRegexCall reports an invalid pattern given as a literal to a regular
+// expression builtin before the program runs.
+func checkRegexCall(e *nodes.Call) {
+	if len(e.Arguments) < 2 {
+		return
+	}
+
+	pattern, ok := e.Arguments[1].(*nodes.StringLiteral)
+	if !ok {
+		return
+	}
+
+	if _, err := regexp.Compile(pattern.Value); err != nil {
+		panic(fmt.Sprintf("Invalid regular expression at %s: %s", pattern.Location, err))
+	}
+}
+
+func builtinMatch(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	return &nodes.BoolLiteral {
+		Value: compileRegex(e, args[1]).MatchString(asString(args[0])),
+		Location: e.Location,
+	}
+}
+
+// builtinFind returns the first match, or an empty string if there is none.
+func builtinFind(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	return &nodes.StringLiteral {
+		Value: compileRegex(e, args[1]).FindString(asString(args[0])),
+		Location: e.Location,
+	}
+}
+
+func builtinFindAll(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	return stringArray(compileRegex(e, args[1]).FindAllString(asString(args[0]), -1), e)
+}
+
+// builtinCaptures returns the first match followed by the text of each of its
+// capture groups, or an empty array if there is no match. Groups that didn't
+// take part in the match are empty strings.
+func builtinCaptures(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	return stringArray(compileRegex(e, args[1]).FindStringSubmatch(asString(args[0])), e)
+}
+
+// builtinCapturesAll is like captures for every match, returning an array of
+// arrays.
+func builtinCapturesAll(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	matches := compileRegex(e, args[1]).FindAllStringSubmatch(asString(args[0]), -1)
+
+	a := &nodes.ArrayLiteral {
+		Elements: make([]nodes.Expression, len(matches)),
+		Location: e.Location,
+	}
+
+	for n, m := range matches {
+		a.Elements[n] = stringArray(m, e)
+	}
+
+	return a
+}
+
+// builtinReplaceRegex replaces every match, expanding $1 in the replacement
+// to the text of the first capture group and so on.
+func builtinReplaceRegex(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	return &nodes.StringLiteral {
+		Value: compileRegex(e, args[1]).ReplaceAllString(asString(args[0]), asString(args[2])),
+		Location: e.Location,
+	}
+}
+
+func builtinSplitRegex(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	return stringArray(compileRegex(e, args[1]).Split(asString(args[0]), -1), e)
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"../nodes"
+)
+
+// String builtins. All positions and lengths are counted in runes (unicode
+// code points) rather than bytes.
+
+func init() {
+	registerBuiltins(
+		builtin{"len", []valueType{stringType | arrayType | mapType}, false, builtinLen, nil},
+		builtin{"substr", []valueType{stringType, intType, intType}, false, builtinSubstr, nil},
+		builtin{"split", []valueType{stringType, stringType}, false, builtinSplit, nil},
+		builtin{"join", []valueType{arrayType, stringType}, false, builtinJoin, nil},
+		builtin{"contains", []valueType{stringType, stringType}, false, builtinContains, nil},
+		builtin{"index", []valueType{stringType, stringType}, false, builtinIndex, nil},
+		builtin{"replace", []valueType{stringType, stringType, stringType}, false, builtinReplace, nil},
+		builtin{"trim", []valueType{stringType}, false, builtinTrim, nil},
+		builtin{"upper", []valueType{stringType}, false, builtinUpper, nil},
+		builtin{"lower", []valueType{stringType}, false, builtinLower, nil},
+		builtin{"repeat", []valueType{stringType, intType}, false, builtinRepeat, nil},
+		builtin{"chars", []valueType{stringType}, false, builtinChars, nil},
+		builtin{"ord", []valueType{stringType}, false, builtinOrd, nil},
+		builtin{"chr", []valueType{intType}, false, builtinChr, nil},
+	)
+}
+
+func builtinLen(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	n := &nodes.IntLiteral {
+		Location: e.Location,
+	}
+
+	switch typeOf(args[0]) {
+	case arrayType:
+		n.Value = len(asArray(args[0]))
+	case mapType:
+		n.Value = len(asMap(args[0]))
+	default:
+		n.Value = utf8.RuneCountInString(asString(args[0]))
+	}
+
+	return n
+}
+
+func builtinSubstr(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	runes := []rune(asString(args[0]))
+	start := asInt(args[1])
+	end := asInt(args[2])
+
+	if start < 0 || start > len(runes) {
+		panic(fmt.Sprintf("Start %d out of range for string of length %d at %s", start, len(runes), args[1].GetLocation()))
+	}
+	if end < start || end > len(runes) {
+		panic(fmt.Sprintf("End %d out of range [%d, %d] at %s", end, start, len(runes), args[2].GetLocation()))
+	}
+
+	return &nodes.StringLiteral {
+		Value: string(runes[start:end]),
+		Location: e.Location,
+	}
+}
+
+func builtinSplit(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	return stringArray(strings.Split(asString(args[0]), asString(args[1])), e)
+}
+
+func builtinJoin(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	elements := asArray(args[0])
+	parts := make([]string, len(elements))
+
+	for n, element := range elements {
+		if typeOf(element) != stringType {
+			panic(fmt.Sprintf("Cannot use token at %s as type string in call to join", element.GetLocation()))
+		}
+		parts[n] = asString(element)
+	}
+
+	return &nodes.StringLiteral {
+		Value: strings.Join(parts, asString(args[1])),
+		Location: e.Location,
+	}
+}
+
+func builtinContains(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	return &nodes.BoolLiteral {
+		Value: strings.Contains(asString(args[0]), asString(args[1])),
+		Location: e.Location,
+	}
+}
+
+func builtinIndex(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	s := asString(args[0])
+
+	index := strings.Index(s, asString(args[1]))
+	if index > 0 {
+		index = utf8.RuneCountInString(s[:index])
+	}
+
+	return &nodes.IntLiteral {
+		Value: index,
+		Location: e.Location,
+	}
+}
+
+func builtinReplace(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	return &nodes.StringLiteral {
+		Value: strings.ReplaceAll(asString(args[0]), asString(args[1]), asString(args[2])),
+		Location: e.Location,
+	}
+}
+
+func builtinTrim(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	return &nodes.StringLiteral {
+		Value: strings.TrimSpace(asString(args[0])),
+		Location: e.Location,
+	}
+}
+
+func builtinUpper(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	return &nodes.StringLiteral {
+		Value: strings.ToUpper(asString(args[0])),
+		Location: e.Location,
+	}
+}
+
+func builtinLower(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	return &nodes.StringLiteral {
+		Value: strings.ToLower(asString(args[0])),
+		Location: e.Location,
+	}
+}
+
+// Largest string repeat makes, far beyond any sensible use but small enough
+// to be allocated rather than crash the interpreter.
+const maxRepeatSize = 1 << 30
+
+func builtinRepeat(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	count := asInt(args[1])
+	if count < 0 {
+		panic(fmt.Sprintf("Negative repeat count %d at %s", count, args[1].GetLocation()))
+	}
+
+	// Checked by division so the size itself can't overflow
+	s := asString(args[0])
+	if len(s) > 0 && count > maxRepeatSize / len(s) {
+		panic(fmt.Sprintf("Repeat count %d at %s makes a string of more than %d bytes", count, args[1].GetLocation(), maxRepeatSize))
+	}
+
+	return &nodes.StringLiteral {
+		Value: strings.Repeat(s, count),
+		Location: e.Location,
+	}
+}
+
+func builtinChars(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	runes := []rune(asString(args[0]))
+	parts := make([]string, len(runes))
+
+	for n, r := range runes {
+		parts[n] = string(r)
+	}
+
+	return stringArray(parts, e)
+}
+
+func builtinOrd(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	runes := []rune(asString(args[0]))
+	if len(runes) != 1 {
+		panic(fmt.Sprintf("Argument to ord at %s must be a single character, got %d", args[0].GetLocation(), len(runes)))
+	}
+
+	return &nodes.IntLiteral {
+		Value: int(runes[0]),
+		Location: e.Location,
+	}
+}
+
+func builtinChr(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	code := asInt(args[0])
+	if code != int(rune(code)) || !utf8.ValidRune(rune(code)) {
+		panic(fmt.Sprintf("Invalid code point %d in call to chr at %s", code, args[0].GetLocation()))
+	}
+
+	return &nodes.StringLiteral {
+		Value: string(rune(code)),
+		Location: e.Location,
+	}
+}
+
+func stringArray(parts []string, e *nodes.Call) *nodes.ArrayLiteral {
+	a := &nodes.ArrayLiteral {
+		Elements: make([]nodes.Expression, len(parts)),
+		Location: e.Location,
+	}
+
+	for n, part := range parts {
+		a.Elements[n] = &nodes.StringLiteral {
+			Value: part,
+			Location: e.Location,
+		}
+	}
+
+	return a
+}
@@ -0,0 +1,499 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+
+	"../nodes"
+	"../location"
+)
+
+// Concurrency. A go statement runs a call in a new task on its own goroutine
+// and tasks communicate through channels. Channels are implemented here
+// rather than with Go channels so that every channel operation happens under
+// one lock, which lets a select wait on any number of channels and lets the
+// scheduler notice when all tasks are blocked.
+
+type task struct {
+	id int
+	scheduler *scheduler
+	// Location of the go statement that started the task, zero for the
+	// main task
+	started location.Location
+	// What the task is waiting for, nil while it's running
+	blocked *waiter
+}
+
+func (t *task) String() string {
+	if t.id == 0 {
+		return "main task"
+	}
+
+	return fmt.Sprintf("task %d started at %s", t.id, t.started)
+}
+
+type scheduler struct {
+	mutex sync.Mutex
+	// Tasks that have not finished, by id
+	tasks map[int] *task
+	nextID int
+	// Number of tasks that are not blocked
+	running int
+}
+
+// newMainTask returns the task running a program, with its own scheduler for
+// the tasks it starts.
+func newMainTask() *task {
+	s := &scheduler {
+		tasks: make(map[int] *task),
+		nextID: 1,
+		running: 1,
+	}
+
+	s.tasks[0] = &task {
+		scheduler: s,
+	}
+
+	return s.tasks[0]
+}
+
+func (s *scheduler) start(l location.Location) *task {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	t := &task {
+		id: s.nextID,
+		scheduler: s,
+		started: l,
+	}
+
+	s.nextID++
+	s.tasks[t.id] = t
+	s.running++
+
+	return t
+}
+
+func (s *scheduler) finish(t *task) {
+	s.mutex.Lock()
+	delete(s.tasks, t.id)
+	s.running--
+	deadlock := s.deadlock()
+	s.mutex.Unlock()
+
+	if deadlock != "" {
+		panic(deadlock)
+	}
+}
+
+// deadlock describes every blocked task if none can run anymore, it must be
+// called with the mutex held.
+func (s *scheduler) deadlock() string {
+	if s.running > 0 || len(s.tasks) == 0 {
+		return ""
+	}
+
+	ids := make([]int, 0, len(s.tasks))
+	for id := range s.tasks {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	blocked := make([]string, len(ids))
+	for n, id := range ids {
+		t := s.tasks[id]
+		blocked[n] = fmt.Sprintf("%s, blocked in %s at %s", t, t.blocked.operation, t.blocked.location)
+	}
+
+	return fmt.Sprintf("Deadlock, all tasks are blocked:\n%s", strings.Join(blocked, "\n"))
+}
+
+// A channel embeds Void to be usable as a value, it has no literal syntax and
+// only exists while the program runs.
+type channel struct {
+	nodes.Void
+	// Type of the values that can be sent, anyType if untyped
+	elementType valueType
+	capacity int
+	buffer []nodes.Expression
+	closed bool
+	// Blocked operations on the channel, oldest first
+	senders []pending
+	receivers []pending
+}
+
+// pending is an operation of a blocked waiter.
+type pending struct {
+	waiter *waiter
+	index int
+}
+
+// operation is a send or receive as done by the builtins, or by a select
+// case.
+type operation struct {
+	channel *channel
+	send bool
+	value nodes.Expression
+}
+
+// waiter is a task blocked on one or more operations, only one of which
+// completes.
+type waiter struct {
+	task *task
+	operations []operation
+	// Describes what's being waited for, for deadlock reports
+	operation string
+	location location.Location
+	// Closed when an operation completes
+	done chan struct{}
+	selected int
+	received nodes.Expression
+	closed bool
+}
+
+// complete finishes operation index of w, waking its task. It must be called
+// with the scheduler's mutex held.
+func (w *waiter) complete(index int, received nodes.Expression, closed bool) {
+	for _, op := range w.operations {
+		op.channel.senders = withoutWaiter(op.channel.senders, w)
+		op.channel.receivers = withoutWaiter(op.channel.receivers, w)
+	}
+
+	w.selected = index
+	w.received = received
+	w.closed = closed
+
+	w.task.blocked = nil
+	w.task.scheduler.running++
+	close(w.done)
+}
+
+func withoutWaiter(ps []pending, w *waiter) []pending {
+	kept := ps[:0]
+	for _, p := range ps {
+		if p.waiter != w {
+			kept = append(kept, p)
+		}
+	}
+
+	return kept
+}
+
+// trySend sends v on c if a receiver is waiting or the buffer has room.
+func (c *channel) trySend(v nodes.Expression, l location.Location) bool {
+	if c.closed {
+		panic(fmt.Sprintf("Send on closed channel at %s", l))
+	}
+
+	if len(c.receivers) > 0 {
+		r := c.receivers[0]
+		r.waiter.complete(r.index, v, false)
+		return true
+	}
+
+	if len(c.buffer) < c.capacity {
+		c.buffer = append(c.buffer, v)
+		return true
+	}
+
+	return false
+}
+
+// tryReceive receives from c if a value is buffered or a sender is waiting,
+// or if c is closed, which is reported by closed.
+func (c *channel) tryReceive() (v nodes.Expression, closed bool, ok bool) {
+	if len(c.buffer) > 0 {
+		v = c.buffer[0]
+		c.buffer = c.buffer[1:]
+
+		// Make room for the oldest blocked sender
+		if len(c.senders) > 0 {
+			s := c.senders[0]
+			c.buffer = append(c.buffer, s.waiter.operations[s.index].value)
+			s.waiter.complete(s.index, nil, false)
+		}
+
+		return v, false, true
+	}
+
+	if len(c.senders) > 0 {
+		s := c.senders[0]
+		v = s.waiter.operations[s.index].value
+		s.waiter.complete(s.index, nil, false)
+
+		return v, false, true
+	}
+
+	return nil, c.closed, c.closed
+}
+
+func (c *channel) close(l location.Location) {
+	if c.closed {
+		panic(fmt.Sprintf("Close of closed channel at %s", l))
+	}
+
+	c.closed = true
+
+	for len(c.receivers) > 0 {
+		r := c.receivers[0]
+		r.waiter.complete(r.index, nil, true)
+	}
+
+	// Blocked senders fail when they wake up
+	for len(c.senders) > 0 {
+		s := c.senders[0]
+		s.waiter.complete(s.index, nil, true)
+	}
+}
+
+// wait completes one of ops, chosen at random among those that can proceed,
+// blocking t until one can. If block is false it returns -1 instead of
+// blocking. For receives it also returns the value received, or reports that
+// the channel was closed.
+func (t *task) wait(ops []operation, block bool, operation string, l location.Location) (int, nodes.Expression, bool) {
+	n, v, closed, w, deadlock := t.tryWait(ops, block, operation, l)
+	if deadlock != "" {
+		panic(deadlock)
+	}
+	if w == nil {
+		return n, v, closed
+	}
+
+	<-w.done
+
+	if ops[w.selected].send && w.closed {
+		panic(fmt.Sprintf("Send on closed channel at %s", l))
+	}
+
+	return w.selected, w.received, w.closed
+}
+
+// tryWait does the part of wait under the scheduler's mutex, it returns the
+// waiter to wait on if no operation could complete.
+func (t *task) tryWait(ops []operation, block bool, operation string, l location.Location) (int, nodes.Expression, bool, *waiter, string) {
+	s := t.scheduler
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, n := range rand.Perm(len(ops)) {
+		if ops[n].send {
+			if ops[n].channel.trySend(ops[n].value, l) {
+				return n, nil, false, nil, ""
+			}
+		} else if v, closed, ok := ops[n].channel.tryReceive(); ok {
+			return n, v, closed, nil, ""
+		}
+	}
+
+	if !block {
+		return -1, nil, false, nil, ""
+	}
+
+	w := &waiter {
+		task: t,
+		operations: ops,
+		operation: operation,
+		location: l,
+		done: make(chan struct{}),
+	}
+
+	for n, op := range ops {
+		if op.send {
+			op.channel.senders = append(op.channel.senders, pending{w, n})
+		} else {
+			op.channel.receivers = append(op.channel.receivers, pending{w, n})
+		}
+	}
+
+	t.blocked = w
+	s.running--
+
+	return 0, nil, false, w, s.deadlock()
+}
+
+func init() {
+	registerBuiltins(
+		builtin{"channel", []valueType{stringType | intType}, true, builtinChannel, nil},
+		builtin{"send", []valueType{channelType, anyType}, false, builtinSend, nil},
+		builtin{"receive", []valueType{channelType, anyType}, true, builtinReceive, nil},
+		builtin{"close", []valueType{channelType}, false, builtinClose, nil},
+	)
+}
+
+// builtinChannel creates a channel, optionally typed by the name of the type
+// of its values and buffered by a capacity, e.g. channel("int", 10).
+func builtinChannel(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	c := &channel {
+		Void: nodes.Void {
+			Location: e.Location,
+		},
+		elementType: anyType,
+		buffer: make([]nodes.Expression, 0),
+		senders: make([]pending, 0),
+		receivers: make([]pending, 0),
+	}
+
+	if len(args) > 0 && typeOf(args[0]) == stringType {
+		name := asString(args[0])
+		c.elementType = 0
+		for n, typeName := range valueTypeNames {
+			if typeName == name {
+				c.elementType = 1 << uint(n)
+			}
+		}
+
+		if c.elementType == 0 {
+			panic(fmt.Sprintf("Unknown type %q at %s, expected one of %s", name, args[0].GetLocation(), strings.Join(valueTypeNames, ", ")))
+		}
+
+		args = args[1:]
+	}
+
+	if len(args) > 0 && typeOf(args[0]) == intType {
+		c.capacity = asInt(args[0])
+		if c.capacity < 0 {
+			panic(fmt.Sprintf("Negative channel capacity at %s", args[0].GetLocation()))
+		}
+
+		args = args[1:]
+	}
+
+	if len(args) > 0 {
+		panic(fmt.Sprintf("Invalid arguments in call to channel at %s, expected channel([type string], [capacity int])", e.Location))
+	}
+
+	return c
+}
+
+// sendOperation checks the arguments of a send and returns the operation.
+func sendOperation(args []nodes.Expression) operation {
+	c := asChannel(args[0])
+	if typeOf(args[1]) & c.elementType == 0 {
+		panic(fmt.Sprintf("Cannot send token at %s on channel of type %s", args[1].GetLocation(), c.elementType))
+	}
+
+	return operation{c, true, args[1]}
+}
+
+// receiveOperation checks the arguments of a receive and returns the
+// operation.
+func receiveOperation(e *nodes.Call, args []nodes.Expression) operation {
+	if len(args) > 2 {
+		panic(fmt.Sprintf("Too many arguments in call to receive at %s, expected %s", e.Location, builtins["receive"]))
+	}
+
+	return operation{channel: asChannel(args[0])}
+}
+
+// received returns the result of a receive, which for a closed channel is
+// its default argument.
+func received(e *nodes.Call, args []nodes.Expression, v nodes.Expression, closed bool) nodes.Expression {
+	if !closed {
+		return v
+	}
+
+	if len(args) < 2 {
+		panic(fmt.Sprintf("Receive from closed channel at %s", e.Location))
+	}
+
+	return args[1]
+}
+
+func builtinSend(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	i.task.wait([]operation{sendOperation(args)}, true, "send", e.Location)
+
+	return &nodes.Void {
+		Location: e.Location,
+	}
+}
+
+// builtinReceive returns the next value sent on a channel. Once the channel
+// is closed and empty it returns the optional second argument, and fails
+// without one.
+func builtinReceive(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	_, v, closed := i.task.wait([]operation{receiveOperation(e, args)}, true, "receive", e.Location)
+
+	return received(e, args, v, closed)
+}
+
+func builtinClose(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	i.task.scheduler.mutex.Lock()
+	defer i.task.scheduler.mutex.Unlock()
+
+	asChannel(args[0]).close(e.Location)
+
+	return &nodes.Void {
+		Location: e.Location,
+	}
+}
+
+func asChannel(v nodes.Expression) *channel {
+	return v.(*channel)
+}
+
+// spawn runs the call of s in a new task. Like in Go the arguments are
+// evaluated by the current task.
+func (i *interpreter) spawn(s *nodes.Go) {
+	b := i.lookupBuiltin(s.Call)
+	args := i.builtinArguments(b, s.Call)
+
+	t := i.task.scheduler.start(s.Location)
+	forked := i.fork(t)
+
+	go func() {
+		b.implementation(forked, s.Call, args)
+		t.scheduler.finish(t)
+	}()
+}
+
+// interpretSelect runs the case of s whose operation completes first, or its
+// default if none can complete immediately.
+func (i *interpreter) interpretSelect(s *nodes.Select) {
+	ops := make([]operation, len(s.Cases))
+	args := make([][]nodes.Expression, len(s.Cases))
+
+	for n, c := range s.Cases {
+		b := i.lookupBuiltin(c.Call)
+		if c.Place != nil && b.name == "send" {
+			panic(fmt.Sprintf("Cannot assign the result of send at %s", c.Location))
+		}
+
+		args[n] = i.builtinArguments(b, c.Call)
+
+		switch b.name {
+		case "send":
+			ops[n] = sendOperation(args[n])
+		case "receive":
+			ops[n] = receiveOperation(c.Call, args[n])
+		default:
+			panic(fmt.Sprintf("Select case at %s is not a send or receive", c.Location))
+		}
+	}
+
+	selected, v, closed := i.task.wait(ops, s.Default == nil, "select", s.Location)
+
+	i.newScope()
+	defer i.deleteScope()
+
+	if selected == -1 {
+		i.interpretStatement(s.Default)
+		return
+	}
+
+	c := s.Cases[selected]
+	if c.Place != nil {
+		place, ok := c.Place.(*nodes.Identifier)
+		if !ok {
+			panic(fmt.Sprintf("Invalid left hand side of assignment at %s", c.Location))
+		}
+
+		i.storeSymbol(place.Name, received(c.Call, args[selected], v, closed))
+	} else if !ops[selected].send {
+		received(c.Call, args[selected], v, closed)
+	}
+
+	i.interpretStatement(c.Body)
+}
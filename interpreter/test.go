@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"../nodes"
+	"../location"
+)
+
+// Testing. Test blocks are skipped when a program runs normally, in test mode
+// the rest of the file runs once and each test then runs with its own copy of
+// the file's variables, so tests can't affect each other.
+
+func init() {
+	registerBuiltins(
+		builtin{"assert", []valueType{boolType, stringType}, false, builtinAssert, nil},
+		builtin{"assertEqual", []valueType{anyType, anyType}, false, builtinAssertEqual, nil},
+	)
+}
+
+// assertionError is the panic of a failed assertion.
+type assertionError struct {
+	message string
+	location location.Location
+}
+
+func (a assertionError) Error() string {
+	return fmt.Sprintf("Assertion failed at %s: %s", a.location, a.message)
+}
+
+func builtinAssert(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	if !args[0].(*nodes.BoolLiteral).Value {
+		panic(assertionError{asString(args[1]), e.Location})
+	}
+
+	return &nodes.Void {
+		Location: e.Location,
+	}
+}
+
+func builtinAssertEqual(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	if !valuesEqual(args[0], args[1]) {
+		panic(assertionError{fmt.Sprintf("%s != %s", describeValue(args[0]), describeValue(args[1])), e.Location})
+	}
+
+	return &nodes.Void {
+		Location: e.Location,
+	}
+}
+
+// valuesEqual compares values by type and content, ints never equal floats.
+func valuesEqual(a nodes.Expression, b nodes.Expression) bool {
+	switch a := a.(type) {
+	case *nodes.IntLiteral:
+		b, ok := b.(*nodes.IntLiteral)
+		return ok && a.Value == b.Value
+	case *nodes.FloatLiteral:
+		b, ok := b.(*nodes.FloatLiteral)
+		return ok && a.Value == b.Value
+	case *nodes.StringLiteral:
+		b, ok := b.(*nodes.StringLiteral)
+		return ok && a.Value == b.Value
+	case *nodes.BoolLiteral:
+		b, ok := b.(*nodes.BoolLiteral)
+		return ok && a.Value == b.Value
+	case *nodes.Void:
+		_, ok := b.(*nodes.Void)
+		return ok
+	case *nodes.ArrayLiteral:
+		b, ok := b.(*nodes.ArrayLiteral)
+		if !ok || len(a.Elements) != len(b.Elements) {
+			return false
+		}
+
+		for n := range a.Elements {
+			if !valuesEqual(a.Elements[n], b.Elements[n]) {
+				return false
+			}
+		}
+
+		return true
+	case *nodes.MapLiteral:
+		b, ok := b.(*nodes.MapLiteral)
+		if !ok || len(a.Values) != len(b.Values) {
+			return false
+		}
+
+		for k, v := range a.Values {
+			if other, exists := b.Values[k]; !exists || !valuesEqual(v, other) {
+				return false
+			}
+		}
+
+		return true
+	default:
+		return a == b
+	}
+}
+
+// describeValue formats a value for a failure message, quoting strings so
+// they can be told apart from other values.
+func describeValue(v nodes.Expression) string {
+	if s, ok := v.(*nodes.StringLiteral); ok {
+		return strconv.Quote(s.Value)
+	}
+
+	if s, ok := formatValue(v); ok {
+		return s
+	}
+
+	return fmt.Sprintf("value at %s", v.GetLocation())
+}
+
+// runTests runs every test of p, reporting the result of each, and returns
+// whether they all passed.
+func runTests(p *nodes.Program, args []string) bool {
+	setup := &nodes.Program {
+		Statements: make([]nodes.Statement, 0),
+		Location: p.Location,
+	}
+	tests := make([]*nodes.Test, 0)
+
+	for _, s := range p.Statements {
+		if t, ok := s.(*nodes.Test); ok {
+			tests = append(tests, t)
+		} else {
+			setup.Statements = append(setup.Statements, s)
+		}
+	}
+
+	i := newInterpreter(args)
+	i.testing = true
+	if err := catch(func() { i.interpretProgram(setup) }); err != nil {
+		fmt.Printf("FAIL setup of %s\n\t%s\n", p.File, strings.Replace(err.Error(), "\n", "\n\t", -1))
+		fmt.Printf("%s in %s, none run\n", plural(len(tests), "test"), p.File)
+		return false
+	}
+
+	failed := 0
+	for _, t := range tests {
+		test := i.forTest()
+		if err := catch(func() { test.interpretStatement(t.Body) }); err != nil {
+			fmt.Printf("FAIL %q at %s\n\t%s\n", t.Name, t.Location, strings.Replace(err.Error(), "\n", "\n\t", -1))
+			failed++
+		} else {
+			fmt.Printf("PASS %q\n", t.Name)
+		}
+	}
+
+	fmt.Printf("%s in %s, %d failed\n", plural(len(tests), "test"), p.File, failed)
+
+	return failed == 0
+}
+
+// forTest returns an interpreter for a test with a copy of the top level
+// variables and imports of i, which has run the rest of the file. Values
+// can't be changed in place, so copying the maps holding them is enough.
+func (i *interpreter) forTest() *interpreter {
+	t := i.fork(i.task)
+
+	i.symbols.RLock()
+	defer i.symbols.RUnlock()
+
+	global := make(map[string] nodes.Expression, len(i.symbolTable[0]))
+	for name, v := range i.symbolTable[0] {
+		global[name] = v
+	}
+	t.symbolTable = []map[string] nodes.Expression{global}
+
+	t.imports = make(map[string] string, len(i.imports))
+	for namespace, path := range i.imports {
+		t.imports[namespace] = path
+	}
+
+	return t
+}
+
+// catch calls f, returning the failure or error that stopped it.
+func catch(f func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(error); ok {
+				err = e
+			} else {
+				err = fmt.Errorf("%v", r)
+			}
+		}
+	}()
+
+	f()
+
+	return nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"../nodes"
+)
+
+// Time and random builtins. Times are ints counting milliseconds since the
+// unix epoch, dates are formatted and parsed in local time with the layouts
+// of Go's time package, e.g. "2006-01-02 15:04:05".
+
+func init() {
+	registerBuiltins(
+		builtin{"now", []valueType{}, false, builtinNow, nil},
+		builtin{"clock", []valueType{}, false, builtinClock, nil},
+		builtin{"sleep", []valueType{intType}, false, builtinSleep, nil},
+		builtin{"formatDate", []valueType{intType, stringType}, false, builtinFormatDate, nil},
+		builtin{"parseDate", []valueType{stringType, stringType}, false, builtinParseDate, nil},
+		builtin{"random", []valueType{}, false, builtinRandom, nil},
+		builtin{"randomInt", []valueType{intType, intType}, false, builtinRandomInt, nil},
+		builtin{"shuffle", []valueType{arrayType}, false, builtinShuffle, nil},
+	)
+}
+
+// Reference point of clock, read from the monotonic clock so it isn't
+// affected by changes to the system time.
+var started time.Time = time.Now()
+
+// Source of the random builtins, seeded by the interpreter's --seed flag to
+// make runs reproducible.
+var random struct {
+	sync.Mutex
+	*rand.Rand
+}
+
+func seedRandom(seed int64) {
+	random.Lock()
+	defer random.Unlock()
+
+	random.Rand = rand.New(rand.NewSource(seed))
+}
+
+func builtinNow(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	return &nodes.IntLiteral {
+		Value: int(time.Now().UnixNano() / int64(time.Millisecond)),
+		Location: e.Location,
+	}
+}
+
+// builtinClock returns the milliseconds elapsed since the program started,
+// as a float, for timing parts of a program.
+func builtinClock(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	return &nodes.FloatLiteral {
+		Value: float64(time.Since(started)) / float64(time.Millisecond),
+		Location: e.Location,
+	}
+}
+
+func builtinSleep(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	if asInt(args[0]) < 0 {
+		panic(fmt.Sprintf("Negative duration in call to sleep at %s", args[0].GetLocation()))
+	}
+
+	time.Sleep(time.Duration(asInt(args[0])) * time.Millisecond)
+
+	return &nodes.Void {
+		Location: e.Location,
+	}
+}
+
+func builtinFormatDate(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	t := time.Unix(0, int64(asInt(args[0])) * int64(time.Millisecond))
+
+	return &nodes.StringLiteral {
+		Value: t.Format(asString(args[1])),
+		Location: e.Location,
+	}
+}
+
+func builtinParseDate(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	t, err := time.ParseInLocation(asString(args[1]), asString(args[0]), time.Local)
+	if err != nil {
+		panic(fmt.Sprintf("Invalid date at %s: %s", args[0].GetLocation(), err))
+	}
+
+	return &nodes.IntLiteral {
+		Value: int(t.UnixNano() / int64(time.Millisecond)),
+		Location: e.Location,
+	}
+}
+
+// builtinRandom returns a float in [0, 1).
+func builtinRandom(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	random.Lock()
+	defer random.Unlock()
+
+	return &nodes.FloatLiteral {
+		Value: random.Float64(),
+		Location: e.Location,
+	}
+}
+
+// builtinRandomInt returns an int between its arguments, inclusive.
+func builtinRandomInt(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	low, high := asInt(args[0]), asInt(args[1])
+	if high < low {
+		panic(fmt.Sprintf("Empty range [%d, %d] in call to randomInt at %s", low, high, e.Location))
+	}
+
+	random.Lock()
+	defer random.Unlock()
+
+	// Ranges with more values than an int holds are drawn in uint64, where
+	// high - low doesn't overflow
+	value := 0
+	if span := high - low; span >= 0 && span < math.MaxInt {
+		value = low + random.Intn(span + 1)
+	} else {
+		value = low + int(uint64n(uint64(high) - uint64(low) + 1))
+	}
+
+	return &nodes.IntLiteral {
+		Value: value,
+		Location: e.Location,
+	}
+}
+
+// uint64n returns a uniform random number in [0, n), or any uint64 if n is
+// 0, i.e. the range wrapped around. random must be locked.
+func uint64n(n uint64) uint64 {
+	if n == 0 {
+		return random.Uint64()
+	}
+
+	// Draws past the last whole multiple of n are redrawn so no value is
+	// more likely than the others
+	limit := math.MaxUint64 - math.MaxUint64 % n
+	r := random.Uint64()
+	for r >= limit {
+		r = random.Uint64()
+	}
+
+	return r % n
+}
+
+// builtinShuffle returns a copy of an array in random order.
+func builtinShuffle(i *interpreter, e *nodes.Call, args []nodes.Expression) nodes.Expression {
+	a := &nodes.ArrayLiteral {
+		Elements: append([]nodes.Expression{}, asArray(args[0])...),
+		Location: e.Location,
+	}
+
+	random.Lock()
+	defer random.Unlock()
+
+	random.Shuffle(len(a.Elements), func(x, y int) {
+		a.Elements[x], a.Elements[y] = a.Elements[y], a.Elements[x]
+	})
+
+	return a
+}
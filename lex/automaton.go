@@ -0,0 +1,401 @@
+package lex
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"../tokens"
+)
+
+// tokenSpec is a token type and the pattern matching its literals.
+type tokenSpec struct {
+	pattern string
+	tokenType tokens.TokenType
+}
+
+// automaton is the minimal deterministic automaton matching the literals of
+// a list of token specs, built from their nfa by the subset construction.
+// Runes are split into intervals which every pattern matches the same way,
+// those in the interval starting at bounds[i] use the column classes[i] of
+// the transition table.
+type automaton struct {
+	bounds []rune
+	classes []int
+	// Column of each ASCII rune, looked up directly as most runes are ASCII
+	ascii [utf8.RuneSelf]int
+	// Next state by state and column, -1 if the automaton is stuck
+	transitions [][]int
+	// transitions flattened row by row, which is quicker to index when
+	// matching
+	table []int
+	columns int
+	// Type emitted by each state, 0 if it doesn't accept
+	accepts []tokens.TokenType
+}
+
+// newAutomaton builds the automaton of specs. When the longest literal is
+// matched by several specs the first one wins, so keywords go before
+// identifiers.
+func newAutomaton(specs []tokenSpec) *automaton {
+	n := &nfa{}
+	start := n.addState()
+
+	for i, spec := range specs {
+		f := compileRegex(n, spec.pattern)
+		n.addEpsilon(start, f.start)
+		n.states[f.end].accept = i
+	}
+
+	a := &automaton{}
+	covers := a.partition(n)
+	a.determinize(n, start, covers, specs)
+	a.minimize()
+	a.compress()
+
+	return a
+}
+
+// partition splits runes into intervals which every transition of n either
+// wholly contains or doesn't overlap, giving intervals in the same
+// transitions the same class. It returns the classes in each transition.
+func (a *automaton) partition(n *nfa) [][]int {
+	starts := map[rune]bool{0: true}
+	for _, s := range n.states {
+		for _, r := range s.set {
+			starts[r.lo] = true
+			starts[r.hi+1] = true
+		}
+	}
+
+	a.bounds = make([]rune, 0, len(starts))
+	for r := range starts {
+		a.bounds = append(a.bounds, r)
+	}
+	sort.Slice(a.bounds, func(i, j int) bool {
+		return a.bounds[i] < a.bounds[j]
+	})
+
+	// The transitions containing each interval
+	containing := make([][]int, len(a.bounds))
+	for id, s := range n.states {
+		for _, r := range s.set {
+			for i := a.interval(r.lo); i < len(a.bounds) && a.bounds[i] <= r.hi; i++ {
+				containing[i] = append(containing[i], id)
+			}
+		}
+	}
+
+	classes := map[string]int{}
+	covers := make([][]int, len(n.states))
+	a.classes = make([]int, len(a.bounds))
+
+	for i, states := range containing {
+		c, exists := classes[key(states)]
+		if !exists {
+			c = len(classes)
+			classes[key(states)] = c
+
+			for _, s := range states {
+				covers[s] = append(covers[s], c)
+			}
+		}
+		a.classes[i] = c
+	}
+
+	return covers
+}
+
+// interval returns the index of the interval containing r.
+func (a *automaton) interval(r rune) int {
+	return sort.Search(len(a.bounds), func(i int) bool {
+		return a.bounds[i] > r
+	}) - 1
+}
+
+// key returns a string identifying ints, for use as a map key.
+func key(ints []int) string {
+	var b strings.Builder
+	for _, n := range ints {
+		b.WriteString(strconv.Itoa(n))
+		b.WriteByte(' ')
+	}
+
+	return b.String()
+}
+
+// closure returns the states reachable from states without reading a rune,
+// sorted.
+func (n *nfa) closure(states []int) []int {
+	seen := map[int]bool{}
+	stack := append([]int{}, states...)
+
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if !seen[s] {
+			seen[s] = true
+			stack = append(stack, n.states[s].epsilon...)
+		}
+	}
+
+	closure := make([]int, 0, len(seen))
+	for s := range seen {
+		closure = append(closure, s)
+	}
+	sort.Ints(closure)
+
+	return closure
+}
+
+// determinize builds a state for each set of nfa states reachable from
+// start, with a column for each class of runes.
+func (a *automaton) determinize(n *nfa, start int, covers [][]int, specs []tokenSpec) {
+	ids := map[string]int{}
+	sets := [][]int{}
+	classes := 0
+	for _, c := range a.classes {
+		if c >= classes {
+			classes = c+1
+		}
+	}
+
+	stateOf := func(states []int) int {
+		states = n.closure(states)
+		if id, exists := ids[key(states)]; exists {
+			return id
+		}
+
+		ids[key(states)] = len(sets)
+		sets = append(sets, states)
+
+		accept := tokens.TokenType(0)
+		best := len(specs)
+		for _, s := range states {
+			if i := n.states[s].accept; i >= 0 && i < best {
+				best = i
+				accept = specs[i].tokenType
+			}
+		}
+		a.accepts = append(a.accepts, accept)
+
+		return len(sets)-1
+	}
+
+	stateOf([]int{start})
+
+	for id := 0; id < len(sets); id++ {
+		targets := make([][]int, classes)
+		for _, s := range sets[id] {
+			for _, c := range covers[s] {
+				targets[c] = append(targets[c], n.states[s].next)
+			}
+		}
+
+		row := make([]int, classes)
+		for c, t := range targets {
+			if t == nil {
+				row[c] = -1
+			} else {
+				row[c] = stateOf(t)
+			}
+		}
+
+		a.transitions = append(a.transitions, row)
+	}
+}
+
+// minimize merges equivalent states by refining a partition of the states
+// by what they accept until states in the same group always move to the
+// same group.
+func (a *automaton) minimize() {
+	group := make([]int, len(a.transitions))
+	groups := 0
+	first := map[tokens.TokenType]int{}
+
+	for s, accept := range a.accepts {
+		if g, exists := first[accept]; exists {
+			group[s] = g
+		} else {
+			first[accept] = groups
+			group[s] = groups
+			groups++
+		}
+	}
+
+	for {
+		next := make([]int, len(group))
+		signatures := map[string]int{}
+
+		for s, row := range a.transitions {
+			signature := []int{group[s]}
+			for _, t := range row {
+				if t < 0 {
+					signature = append(signature, -1)
+				} else {
+					signature = append(signature, group[t])
+				}
+			}
+
+			g, exists := signatures[key(signature)]
+			if !exists {
+				g = len(signatures)
+				signatures[key(signature)] = g
+			}
+			next[s] = g
+		}
+
+		group = next
+		if len(signatures) == groups {
+			break
+		}
+		groups = len(signatures)
+	}
+
+	// The start state stays first
+	order := map[int]int{group[0]: 0}
+	for _, g := range group {
+		if _, exists := order[g]; !exists {
+			order[g] = len(order)
+		}
+	}
+
+	transitions := make([][]int, groups)
+	accepts := make([]tokens.TokenType, groups)
+	for s, row := range a.transitions {
+		g := order[group[s]]
+		if transitions[g] != nil {
+			continue
+		}
+
+		transitions[g] = make([]int, len(row))
+		for i, t := range row {
+			if t < 0 {
+				transitions[g][i] = -1
+			} else {
+				transitions[g][i] = order[group[t]]
+			}
+		}
+		accepts[g] = a.accepts[s]
+	}
+
+	a.transitions = transitions
+	a.accepts = accepts
+}
+
+// compress shares the columns of classes which every state treats the same
+// and merges neighbouring intervals sharing a column.
+func (a *automaton) compress() {
+	columns := map[string]int{}
+	columnOf := make([]int, len(a.transitions[0]))
+
+	for c := range columnOf {
+		column := make([]int, len(a.transitions))
+		for s, row := range a.transitions {
+			column[s] = row[c]
+		}
+
+		if i, exists := columns[key(column)]; exists {
+			columnOf[c] = i
+		} else {
+			columnOf[c] = len(columns)
+			columns[key(column)] = len(columns)
+		}
+	}
+
+	bounds := []rune{}
+	classes := []int{}
+	for i, c := range a.classes {
+		if n := len(classes); n > 0 && classes[n-1] == columnOf[c] {
+			continue
+		}
+		bounds = append(bounds, a.bounds[i])
+		classes = append(classes, columnOf[c])
+	}
+
+	for s, row := range a.transitions {
+		compressed := make([]int, len(columns))
+		for c, t := range row {
+			compressed[columnOf[c]] = t
+		}
+		a.transitions[s] = compressed
+	}
+
+	a.bounds = bounds
+	a.classes = classes
+
+	for r := range a.ascii {
+		a.ascii[r] = a.classes[a.interval(rune(r))]
+	}
+
+	a.columns = len(columns)
+	for _, row := range a.transitions {
+		a.table = append(a.table, row...)
+	}
+}
+
+// step returns the state reached from state on r, or -1.
+func (a *automaton) step(state int, r rune) int {
+	if r < utf8.RuneSelf {
+		return a.table[state*a.columns + a.ascii[r]]
+	}
+
+	return a.table[state*a.columns + a.classes[a.interval(r)]]
+}
+
+// match returns the type and literal of the longest token starting at the
+// next rune of rq and leaves rq after it. If no token starts there an Error
+// and its message are returned, rq is left after the runes read.
+func (a *automaton) match(rq *stringQueue) (tokens.TokenType, string) {
+	start := rq.i
+
+	// Type and end of the longest token read so far
+	accepted := tokens.TokenType(0)
+	end := start
+
+	// Runes are read from rq.queue directly, rq is moved once the token is
+	// found
+	i := start
+	for state := 0; i < len(rq.queue); {
+		// ASCII runes are looked up here rather than in step, as most are
+		size := 1
+		if c := rq.queue[i]; c < utf8.RuneSelf {
+			state = a.table[state*a.columns + a.ascii[c]]
+		} else {
+			var r rune
+			r, size = utf8.DecodeRuneInString(rq.queue[i:])
+			state = a.step(state, r)
+		}
+
+		if state < 0 {
+			break
+		}
+		i += size
+
+		if a.accepts[state] != 0 {
+			accepted, end = a.accepts[state], i
+		}
+	}
+
+	if accepted != 0 {
+		rq.moveTo(end)
+		return accepted, rq.queue[start:end]
+	}
+
+	matched := rq.queue[start:i]
+	rq.moveTo(i)
+	r, done := rq.peek()
+
+	if done {
+		return tokens.Error, fmt.Sprintf("Unexpected EOF after %q", matched)
+	} else if matched == "" {
+		rq.next()
+		return tokens.Error, fmt.Sprintf("Unexpected character %q", r)
+	}
+
+	// The rune isn't consumed, it may start the next token
+	return tokens.Error, fmt.Sprintf("Unexpected %q after %q", r, matched)
+}
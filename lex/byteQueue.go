@@ -0,0 +1,244 @@
+package lex
+
+import (
+	"io"
+	"unicode/utf8"
+)
+
+// Least space read into at a time
+const minRead = 4096
+
+// byteQueue reads a source into a buffer as it's needed and decodes its runes
+// on demand, so tokens can be emitted as soon as they are read rather than at
+// the end of the input. Literals are sliced from the buffer, which keeps the
+// source from mark, the start of the token being read, when more is read.
+type byteQueue struct {
+	reader io.Reader
+	buf []byte
+	// Index in buf of the current rune r, which takes size bytes, 0 at the
+	// end of the source
+	i int
+	r rune
+	size int
+	mark int
+	eof bool
+	// Error that ended reading the source early, see readError
+	err error
+	file string
+	// Offset in the source of buf[0]
+	base uint
+	// Line of the current rune, the offset its line starts at and the bytes
+	// before it on the line past the first of each rune, so its column is
+	// worked out from its offset rather than counted rune by rune
+	line uint
+	lineStart uint
+	extra uint
+}
+
+func newByteQueue(reader io.Reader, fileName string) *byteQueue {
+	q := &byteQueue {
+		reader: reader,
+		buf: make([]byte, 0, 16 * minRead),
+		file: fileName,
+		line: 1,
+	}
+	q.r, q.size = q.decode(0)
+
+	return q
+}
+
+// fill reads more of the source into buf, dropping the source before mark,
+// and reports whether there was more.
+func (q *byteQueue) fill() bool {
+	if q.eof {
+		return false
+	}
+
+	if q.mark > 0 {
+		n := copy(q.buf, q.buf[q.mark:])
+		q.buf = q.buf[:n]
+		q.i -= q.mark
+		q.base += uint(q.mark)
+		q.mark = 0
+	}
+
+	// Grown when a token takes most of buf
+	if cap(q.buf) - len(q.buf) < minRead {
+		grown := make([]byte, len(q.buf), 2 * cap(q.buf))
+		copy(grown, q.buf)
+		q.buf = grown
+	}
+
+	for {
+		n, err := q.reader.Read(q.buf[len(q.buf):cap(q.buf)])
+		q.buf = q.buf[:len(q.buf)+n]
+
+		if err != nil {
+			if err != io.EOF {
+				q.err = err
+			}
+			q.eof = true
+			return n > 0
+		}
+
+		if n > 0 {
+			return true
+		}
+	}
+}
+
+// decode returns the rune k bytes after the current one and its size, which
+// is 0 at the end of the source.
+func (q *byteQueue) decode(k int) (rune, int) {
+	for q.i+k >= len(q.buf) || (q.buf[q.i+k] >= utf8.RuneSelf && !utf8.FullRune(q.buf[q.i+k:])) {
+		if !q.fill() {
+			break
+		}
+	}
+
+	if q.i+k >= len(q.buf) {
+		return 0, 0
+	}
+
+	if b := q.buf[q.i+k]; b < utf8.RuneSelf {
+		return rune(b), 1
+	}
+
+	return utf8.DecodeRune(q.buf[q.i+k:])
+}
+
+// position returns the position of the current rune.
+func (q *byteQueue) position() position {
+	offset := q.base + uint(q.i)
+
+	return position {
+		line: q.line,
+		column: offset - q.lineStart - q.extra + 1,
+		offset: offset,
+	}
+}
+
+// buffered returns the number of bytes read from the current rune on.
+func (q *byteQueue) buffered() int {
+	return len(q.buf) - q.i
+}
+
+func (q *byteQueue) current() (rune, bool) {
+	return q.r, q.size == 0
+}
+
+func (q *byteQueue) next() (rune, bool) {
+	if q.size == 0 {
+		return 0, true
+	}
+
+	if q.r == '\n' {
+		q.line += 1
+		q.lineStart = q.base + uint(q.i) + 1
+		q.extra = 0
+	} else {
+		q.extra += uint(q.size - 1)
+	}
+
+	q.i += q.size
+	// Most source is ASCII already in buf, which needs no decoding
+	if q.i < len(q.buf) && q.buf[q.i] < utf8.RuneSelf {
+		q.r, q.size = rune(q.buf[q.i]), 1
+	} else {
+		q.r, q.size = q.decode(0)
+	}
+
+	return q.r, q.size == 0
+}
+
+// skip moves past the run of ASCII runes from the current one in the set
+// in, which mustn't hold '\n'.
+func (q *byteQueue) skip(in *[utf8.RuneSelf]bool) {
+	for q.r < utf8.RuneSelf && q.size == 1 && in[q.r] {
+		j := q.i + 1
+		for j < len(q.buf) && q.buf[j] < utf8.RuneSelf && in[q.buf[j]] {
+			j++
+		}
+
+		q.i = j
+		q.r, q.size = q.decode(0)
+	}
+}
+
+// skipUntil moves to the next rune which is an ASCII rune in the set stop,
+// or to the end of the source, and returns it. stop must hold '\n'.
+func (q *byteQueue) skipUntil(stop *[utf8.RuneSelf]bool) (rune, bool) {
+	for q.size > 0 && !(q.r < utf8.RuneSelf && stop[q.r]) {
+		j := q.i
+		ascii := true
+		for j < len(q.buf) && !(q.buf[j] < utf8.RuneSelf && stop[q.buf[j]]) {
+			ascii = ascii && q.buf[j] < utf8.RuneSelf
+			j++
+		}
+
+		if !ascii {
+			// A rune cut off by the end of buf is decoded once it's read
+			if j == len(q.buf) {
+				j = lastRuneStart(q.buf[q.i:j]) + q.i
+			}
+			q.extra += uint(j - q.i - utf8.RuneCount(q.buf[q.i:j]))
+		}
+
+		if j == q.i {
+			// The rune cut off is the current one
+			q.next()
+			continue
+		}
+
+		q.i = j
+		q.r, q.size = q.decode(0)
+	}
+
+	return q.r, q.size == 0
+}
+
+// lastRuneStart returns the index in b of its last rune if that may be
+// incomplete, else len(b).
+func lastRuneStart(b []byte) int {
+	for k := len(b) - 1; k >= 0 && k >= len(b) - utf8.UTFMax; k-- {
+		if utf8.RuneStart(b[k]) {
+			if utf8.FullRune(b[k:]) {
+				return len(b)
+			}
+			return k
+		}
+	}
+
+	return len(b)
+}
+
+// readError returns the error that ended reading the source before its end,
+// once, or nil.
+func (q *byteQueue) readError() error {
+	err := q.err
+	q.err = nil
+
+	return err
+}
+
+// peek returns the rune after the current one, it blocks until that rune has
+// been read so should only be used when needed to finish the current token.
+func (q *byteQueue) peek() (rune, bool) {
+	if q.size == 0 {
+		return 0, true
+	}
+
+	r, size := q.decode(q.size)
+
+	return r, size == 0
+}
+
+// start marks the current rune as the start of a token.
+func (q *byteQueue) start() {
+	q.mark = q.i
+}
+
+// token returns the source from the start of the token to the current rune.
+func (q *byteQueue) token() []byte {
+	return q.buf[q.mark:q.i]
+}
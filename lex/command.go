@@ -0,0 +1,93 @@
+package lex
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"../tokens"
+	"../location"
+)
+
+// Main runs a lexer command, the lexer and lexer2 commands only differ in
+// the implementation they use, described by about in their usage. It lexes
+// each file named on the command line, or stdin if there are none, printing
+// the tokens to stdout, and exits with status 1 if any were errors.
+func Main(implementation Implementation, about string) {
+	fileName := flag.String("name", "stdin", "file name used in the locations of tokens read from stdin")
+	lossless := flag.Bool("lossless", false, "print the source text and trivia of each token and an EOF token, from which the source can be rebuilt")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] [files...]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Lexes stdin if no file is given, %s.\n", about)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	// Errors are printed as tokens as well, the parser stops on them
+	errors := make([]tokens.Token, 0)
+	emit := func(t tokens.Token) {
+		if t.Type == tokens.Error {
+			errors = append(errors, t)
+		}
+
+		// Only the EOF of a lossless stream carries anything
+		if *lossless {
+			fmt.Println(t.LosslessString())
+		} else if t.Type != tokens.EOF {
+			fmt.Println(t.String())
+		}
+	}
+
+	lexReader := func(reader io.Reader, fileName string) {
+		l := New(reader, Options {
+			FileName: fileName,
+			Implementation: implementation,
+			Lossless: *lossless,
+		})
+
+		for t := l.Next(); ; t = l.Next() {
+			emit(t)
+
+			if t.Type == tokens.EOF {
+				return
+			}
+		}
+	}
+
+	if flag.NArg() > 0 {
+		for _, fileName := range flag.Args() {
+			f, err := os.Open(fileName)
+			if err != nil {
+				// Printed as a token too, so the parser doesn't take the
+				// file for an empty source
+				start := location.Location{File: fileName, Line: 1, Column: 1}
+				emit(tokens.Errorf(start.To(start), "Failed to open source, %s", err))
+				continue
+			}
+
+			lexReader(f, fileName)
+			f.Close()
+		}
+	} else {
+		lexReader(os.Stdin, *fileName)
+	}
+
+	if len(errors) > 0 {
+		reportErrors(errors)
+		os.Exit(1)
+	}
+}
+
+// reportErrors prints every error found to stderr followed by their count.
+func reportErrors(errors []tokens.Token) {
+	for _, e := range errors {
+		fmt.Fprintf(os.Stderr, "%s at %s\n", e.Literal, e.Location)
+	}
+
+	if len(errors) == 1 {
+		fmt.Fprintln(os.Stderr, "1 error")
+	} else {
+		fmt.Fprintf(os.Stderr, "%d errors\n", len(errors))
+	}
+}
@@ -0,0 +1,382 @@
+package lex
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"../tokens"
+	"../location"
+)
+
+// stringQueue reads the runes of a source file, i is the byte offset of the
+// next one. Like byteQueue it keeps the line of the next rune, the offset
+// its line starts at and the bytes before it on the line past the first of
+// each rune, and works out its column from these.
+type stringQueue struct {
+	i int
+	queue string
+	file string
+	line uint
+	lineStart uint
+	extra uint
+}
+
+// decode returns the next rune and its size, there must be one.
+func (rq *stringQueue) decode() (rune, int) {
+	if c := rq.queue[rq.i]; c < utf8.RuneSelf {
+		return rune(c), 1
+	}
+
+	return utf8.DecodeRuneInString(rq.queue[rq.i:])
+}
+
+// position returns the position of the next rune.
+func (rq *stringQueue) position() position {
+	offset := uint(rq.i)
+
+	return position {
+		line: rq.line,
+		column: offset - rq.lineStart - rq.extra + 1,
+		offset: offset,
+	}
+}
+
+func (rq *stringQueue) next() (rune, bool) {
+	if rq.i < len(rq.queue) {
+		r, size := rq.decode()
+		if r == '\n' {
+			rq.line += 1
+			rq.lineStart = uint(rq.i) + 1
+			rq.extra = 0
+		} else {
+			rq.extra += uint(size - 1)
+		}
+
+		rq.i += size
+
+		return r, false
+	} else {
+		return 0, true
+	}
+}
+
+// moveTo moves rq forward to the byte offset end.
+func (rq *stringQueue) moveTo(end int) {
+	// Runes only need counting on the last line if any aren't ASCII
+	from, ascii := rq.i, true
+	for k := rq.i; k < end; k++ {
+		if c := rq.queue[k]; c == '\n' {
+			rq.line += 1
+			rq.lineStart = uint(k) + 1
+			rq.extra = 0
+			from, ascii = k+1, true
+		} else if c >= utf8.RuneSelf {
+			ascii = false
+		}
+	}
+
+	if !ascii {
+		rq.extra += uint(end - from - utf8.RuneCountInString(rq.queue[from:end]))
+	}
+	rq.i = end
+}
+
+// skip moves past the run of ASCII runes from the next one in the set in,
+// which mustn't hold '\n'.
+func (rq *stringQueue) skip(in *[utf8.RuneSelf]bool) {
+	for rq.i < len(rq.queue) && rq.queue[rq.i] < utf8.RuneSelf && in[rq.queue[rq.i]] {
+		rq.i++
+	}
+}
+
+func (rq *stringQueue) peek() (rune, bool) {
+	if rq.i < len(rq.queue) {
+		r, _ := rq.decode()
+		return r, false
+	} else {
+		return 0, true
+	}
+}
+
+// peekSecond returns the rune after the one returned by peek.
+func (rq *stringQueue) peekSecond() (rune, bool) {
+	if rq.i < len(rq.queue) {
+		_, size := utf8.DecodeRuneInString(rq.queue[rq.i:])
+		if rq.i+size < len(rq.queue) {
+			r, _ := utf8.DecodeRuneInString(rq.queue[rq.i+size:])
+			return r, false
+		}
+	}
+
+	return 0, true
+}
+
+func stringQueueFromString(s string) stringQueue {
+	return stringQueue {
+		i: 0,
+		queue: s,
+		line: 1,
+	}
+}
+
+// dfaScanner lexes with automata generated from the token specs in
+// dfaDef.go. The source is read as a whole before the first token as
+// comments and strings may span lines.
+type dfaScanner struct {
+	reader io.Reader
+	fileName string
+	rq *stringQueue
+	// Tokens found by the current step
+	found []tokens.Token
+	// Error that ended reading the source early, reported at the end of
+	// what was read
+	err error
+	// Brace depth inside each interpolated expression being lexed,
+	// innermost last.
+	interpolations []int
+}
+
+var lexerAutomaton *automaton
+var interpolationAutomaton *automaton
+var buildAutomata sync.Once
+
+func newDfaScanner(reader io.Reader, fileName string) *dfaScanner {
+	// Built when first needed as it takes a while
+	buildAutomata.Do(func() {
+		lexerAutomaton = newAutomaton(tokenSpecs)
+		interpolationAutomaton = newAutomaton(interpolationSpecs)
+	})
+
+	return &dfaScanner {
+		reader: reader,
+		fileName: fileName,
+		interpolations: make([]int, 0),
+	}
+}
+
+// emit adds a token from start to the next rune.
+func (s *dfaScanner) emit(tokenType tokens.TokenType, literal string, start position) {
+	s.found = addToken(s.found, tokenType, literal, s.fileName, start, s.rq.position())
+}
+
+// step finds up to maxFound tokens, the whole source is already read so
+// there's nothing to wait for.
+func (s *dfaScanner) step(found []tokens.Token) ([]tokens.Token, bool) {
+	s.found = found
+
+	more := s.scan()
+	for more && len(s.found) - len(found) < maxFound {
+		more = s.scan()
+	}
+
+	return s.found, more
+}
+
+// scan matches the token at the current rune and reports whether there is
+// more of the source.
+func (s *dfaScanner) scan() bool {
+	if s.rq == nil {
+		buff, err := ioutil.ReadAll(s.reader)
+		s.err = err
+
+		rq := stringQueueFromString(string(buff))
+		s.rq = &rq
+	}
+	rq := s.rq
+
+	r, done := rq.peek()
+	if done {
+		if len(s.interpolations) > 0 {
+			s.emit(tokens.Error, "Unterminated string literal, unexpected EOF in interpolation", rq.position())
+			s.interpolations = s.interpolations[:0]
+		}
+		if s.err != nil {
+			s.emit(tokens.Error, fmt.Sprintf("Failed to read source, %s", s.err), rq.position())
+			s.err = nil
+		}
+		return false
+	}
+
+	start := rq.position()
+
+	// Comments aren't matched by the automaton as block comments nest.
+	// They are checked first so a '}' in a comment doesn't end an
+	// interpolation.
+	if r == '/' {
+		if next, _ := rq.peekSecond(); next == '/' || next == '*' {
+			s.skipComment()
+			return true
+		}
+	}
+
+	if top := len(s.interpolations) - 1; top >= 0 {
+		if r == '{' {
+			s.interpolations[top]++
+		} else if r == '}' && s.interpolations[top] > 0 {
+			s.interpolations[top]--
+		} else if r == '}' {
+			// End of the interpolated expression, carry on with the string
+			s.interpolations = s.interpolations[:top]
+
+			tokenType, literal := matchString(interpolationAutomaton, rq)
+			if tokenType == tokens.InterpolationMiddle {
+				s.interpolations = append(s.interpolations, 0)
+			}
+			s.emitDecoded(tokenType, literal, start)
+
+			return true
+		}
+	}
+
+	if unicode.IsSpace(r) {
+		for !done && unicode.IsSpace(r) {
+			rq.next()
+			rq.skip(&blanks)
+			r, done = rq.peek()
+		}
+		return true
+	}
+
+	var tokenType tokens.TokenType
+	var literal string
+	if r == '"' {
+		tokenType, literal = matchString(lexerAutomaton, rq)
+	} else {
+		tokenType, literal = lexerAutomaton.match(rq)
+	}
+
+	if tokenType == tokens.InterpolationStart {
+		s.interpolations = append(s.interpolations, 0)
+	}
+	s.emitDecoded(tokenType, literal, start)
+
+	return true
+}
+
+func (s *dfaScanner) end() location.Location {
+	p := s.rq.position()
+	return span(s.fileName, p, p)
+}
+
+// matchString matches a piece of a string literal with a, which can only
+// fail to match it if the source ends first.
+func matchString(a *automaton, rq *stringQueue) (tokens.TokenType, string) {
+	tokenType, literal := a.match(rq)
+	if tokenType == tokens.Error {
+		literal = "Unterminated string literal"
+	}
+
+	return tokenType, literal
+}
+
+// skipComment skips a line comment, or a block comment which may contain
+// nested block comments. A doc comment, a line comment starting with exactly
+// three slashes, is emitted holding the rest of its line, as is the Error
+// of a block comment left open.
+func (s *dfaScanner) skipComment() {
+	rq := s.rq
+	start := rq.position()
+	rq.next()
+
+	if r, _ := rq.next(); r == '/' {
+		from := rq.i
+		rq.skip(&notLineEnd)
+		for r, done := rq.peek(); !done && r != '\n'; r, done = rq.peek() {
+			rq.next()
+			rq.skip(&notLineEnd)
+		}
+
+		text := rq.queue[from:rq.i]
+		if len(text) == 0 || text[0] != '/' || (len(text) > 1 && text[1] == '/') {
+			return
+		}
+
+		// Each byte of invalid UTF-8 reads as U+FFFD, as when lexed by
+		// matchers
+		literal := text[1:]
+		if !utf8.ValidString(literal) {
+			literal = string([]rune(literal))
+		}
+
+		s.emit(tokens.DocComment, literal, start)
+		return
+	}
+
+	for depth := 1; depth > 0; {
+		r, done := rq.next()
+		if done {
+			s.emit(tokens.Error, "Unterminated block comment", start)
+			return
+		}
+
+		if next, _ := rq.peek(); r == '/' && next == '*' {
+			depth++
+			rq.next()
+		} else if r == '*' && next == '/' {
+			depth--
+			rq.next()
+		}
+	}
+}
+
+// ASCII runes other than '\n', skipped in bulk in line comments
+var notLineEnd [utf8.RuneSelf]bool
+
+func init() {
+	for r := range notLineEnd {
+		notLineEnd[r] = r != '\n'
+	}
+}
+
+// emitDecoded emits a token from start of type tokenType, stripping the
+// delimiters from a matched piece of a string literal and decoding its
+// escape sequences. A piece with an invalid escape sequence is emitted
+// undecoded followed by an Error token spanning the escape. Number literals
+// are checked and given their type, or replaced by an Error, and identifiers
+// are normalized.
+func (s *dfaScanner) emitDecoded(tokenType tokens.TokenType, literal string, start position) {
+	if tokenType == tokens.Identifier {
+		s.emit(tokenType, tokens.NFC(literal), start)
+		return
+	}
+
+	if tokenType == tokens.IntLiteral {
+		numberType, err := tokens.NumberType(literal)
+		if err != nil {
+			s.emit(tokens.Error, err.Error(), start)
+			return
+		}
+
+		s.emit(numberType, literal, start)
+		return
+	}
+
+	if !tokenType.IsString() || tokenType == tokens.Error {
+		s.emit(tokenType, literal, start)
+		return
+	}
+
+	// Pieces open with '"' or '}' and close with '"' or "${"
+	body := literal[1:]
+	if tokenType == tokens.InterpolationStart || tokenType == tokens.InterpolationMiddle {
+		body = body[:len(body)-2]
+	} else {
+		body = body[:len(body)-1]
+	}
+
+	value, escStart, escEnd, err := tokens.UnescapeString(body)
+	if err != nil {
+		// Each byte of invalid UTF-8 reads as U+FFFD, as when unescaped
+		s.emit(tokenType, string([]rune(body)), start)
+
+		at := span(s.fileName, start, start).Advance(literal[:1]+body[:escStart])
+		s.found = append(s.found, tokens.Errorf(at.To(at.Advance(body[escStart:escEnd])), "Invalid string literal, %s", err))
+		return
+	}
+
+	s.emit(tokenType, value, start)
+}
@@ -0,0 +1,78 @@
+package lex
+
+import (
+	"../tokens"
+)
+
+// Body of a piece of a string literal: any rune but a quote, escape
+// sequences, and '$' unless it opens an interpolation with "${".
+const stringBody = `([^"\\$]|\\.|\$+([^"\\${]|\\.))*\$*`
+
+// Number literals match every rune that could belong to them, so e.g. 1.2.3
+// is one malformed literal rather than several tokens. The lexer checks
+// them and sets their type, they are all matched as IntLiteral. In hex
+// literals e is a digit, elsewhere it may be followed by the exponent's sign.
+const numberRune = `[_.\pL\d--eE]`
+const numberTail = `(` + numberRune + `|[eE][+\-]?)*`
+
+// Identifiers as in tokens.IsIdentifierStart and IsIdentifierContinue, the
+// ID_Start and ID_Continue properties of UAX #31 derived from categories.
+const idStart = `\pL\p{Nl}\p{Other_ID_Start}`
+const notIdentifier = `\p{Pattern_Syntax}\p{Pattern_White_Space}`
+const identifier = `[_` + idStart + `--` + notIdentifier + `][` + idStart + `\p{Mn}\p{Mc}\d\p{Pc}\p{Other_ID_Continue}--` + notIdentifier + `]*`
+
+var tokenSpecs = []tokenSpec {
+	{`"` + stringBody + `"`, tokens.StringLiteral},
+	{`"` + stringBody + `\$\{`, tokens.InterpolationStart},
+
+	{`[\d--0]` + numberTail, tokens.IntLiteral},
+	{`0(([_.\pL\d--eExX]|[eE][+\-]?)` + numberTail + `)?`, tokens.IntLiteral},
+	{`0[xX][_.\pL\d]*`, tokens.IntLiteral},
+	{`\.\d` + numberTail, tokens.IntLiteral},
+
+	{`\+`, tokens.Add},
+	{`\+\+`, tokens.Increment},
+	{`-`, tokens.Subtract},
+	{`--`, tokens.Decrement},
+	{`\*`, tokens.Multiply},
+	{`/`, tokens.Divide},
+	{`<`, tokens.LessThan},
+	{`>`, tokens.GreaterThan},
+	{`=`, tokens.Assignment},
+	{`==`, tokens.EqualTo},
+
+	{`;`, tokens.Semicolon},
+	{`,`, tokens.Comma},
+	{`\.`, tokens.Dot},
+	{`\(`, tokens.OpenBracket},
+	{`\)`, tokens.CloseBracket},
+	{`\{`, tokens.OpenCurlyBracket},
+	{`\}`, tokens.CloseCurlyBracket},
+	{`\[`, tokens.OpenSquareBracket},
+	{`\]`, tokens.CloseSquareBracket},
+
+	{`for`, tokens.For},
+	{`if`, tokens.If},
+	{`else`, tokens.Else},
+	{`return`, tokens.Return},
+	{`break`, tokens.Break},
+	{`continue`, tokens.Continue},
+	{`var`, tokens.Var},
+	{`const`, tokens.Const},
+	{`import`, tokens.Import},
+	{`go`, tokens.Go},
+	{`select`, tokens.Select},
+	{`case`, tokens.Case},
+	{`default`, tokens.Default},
+	{`test`, tokens.Test},
+	{`true`, tokens.BoolLiteral},
+	{`false`, tokens.BoolLiteral},
+	{identifier, tokens.Identifier},
+}
+
+// Continues a string literal after an interpolated expression, only used
+// by the lexer when the '}' closing the expression is reached.
+var interpolationSpecs = []tokenSpec {
+	{`\}` + stringBody + `"`, tokens.InterpolationEnd},
+	{`\}` + stringBody + `\$\{`, tokens.InterpolationMiddle},
+}
@@ -0,0 +1,152 @@
+// Package lex turns source files into tokens. It has two implementations
+// which produce the same tokens: Matchers, which reads the source only as
+// far as the current token so tokens are available as soon as they're read,
+// and DFA, which runs automata generated from the token patterns in
+// dfaDef.go over the whole source.
+package lex
+
+import (
+	"io"
+
+	"../tokens"
+	"../location"
+)
+
+type Implementation int
+
+const (
+	Matchers Implementation = iota
+	DFA
+)
+
+type Options struct {
+	// Used in the locations of tokens
+	FileName string
+	Implementation Implementation
+	// Gives tokens their source text and trivia, see tokens.Lossless
+	Lossless bool
+}
+
+// scanner lexes a source a step at a time, appending the tokens found to
+// found, and reports whether there is more of the source.
+type scanner interface {
+	step(found []tokens.Token) ([]tokens.Token, bool)
+	// Location reached at the end of the source
+	end() location.Location
+}
+
+// position is a point in a source. Scanners keep these rather than
+// locations, which are larger to copy, until they make a token.
+type position struct {
+	line uint
+	column uint
+	offset uint
+}
+
+// addToken appends a token from start to end to found, setting its fields
+// in place as tokens are large to copy.
+func addToken(found []tokens.Token, tokenType tokens.TokenType, literal string, file string, start position, end position) []tokens.Token {
+	found = append(found, tokens.Token{})
+	t := &found[len(found)-1]
+
+	t.Type = tokenType
+	t.Literal = literal
+	t.File = file
+	t.Line, t.Column, t.Offset = start.line, start.column, start.offset
+	t.EndLine, t.EndColumn, t.EndOffset = end.line, end.column, end.offset
+
+	return found
+}
+
+// span returns the location from start to end.
+func span(file string, start position, end position) location.Location {
+	return location.Location {
+		File: file,
+		Line: start.line,
+		Column: start.column,
+		Offset: start.offset,
+		EndLine: end.line,
+		EndColumn: end.column,
+		EndOffset: end.offset,
+	}
+}
+
+// Lexer returns the tokens of a source one at a time. Bad input is returned
+// as Error tokens and lexing carries on after it, so every problem in a
+// source is reported. An error reading the source ends it, and is returned
+// as an Error token before the EOF.
+type Lexer struct {
+	scanner scanner
+	lossless *tokens.Lossless
+	// Tokens found, those from next up to ready haven't been returned yet.
+	// In lossless mode those from ready on are held for their trivia.
+	pending []tokens.Token
+	next int
+	ready int
+	eof *tokens.Token
+}
+
+func New(reader io.Reader, options Options) *Lexer {
+	l := &Lexer {
+		pending: make([]tokens.Token, 0),
+	}
+
+	if options.Lossless {
+		l.lossless = tokens.NewLossless()
+		// The source is copied to l.lossless as it's read
+		reader = io.TeeReader(reader, l.lossless)
+	}
+
+	if options.Implementation == DFA {
+		l.scanner = newDfaScanner(reader, options.FileName)
+	} else {
+		l.scanner = newMatcherScanner(reader, options.FileName)
+	}
+
+	return l
+}
+
+// Next returns the next token, or an EOF token at the end of the source once
+// every token has been returned. In lossless mode the first EOF token holds
+// the trivia at the end of the source.
+func (l *Lexer) Next() tokens.Token {
+	for l.next == l.ready {
+		if l.eof != nil {
+			return *l.eof
+		}
+
+		// Reused rather than grown, keeping only the held tokens
+		n := copy(l.pending, l.pending[l.ready:])
+		l.pending = l.pending[:n]
+		l.next, l.ready = 0, 0
+
+		found := len(l.pending)
+		more := false
+		l.pending, more = l.scanner.step(l.pending)
+
+		if l.lossless != nil {
+			l.ready = l.lossless.Add(l.pending, l.ready, found)
+		} else {
+			l.ready = len(l.pending)
+		}
+
+		if !more {
+			end := l.scanner.end()
+			l.eof = &tokens.Token {
+				Type: tokens.EOF,
+				Location: end.To(end),
+			}
+
+			if l.lossless != nil {
+				l.pending = append(l.pending, l.lossless.Close(l.pending[l.ready:], end))
+			} else {
+				l.pending = append(l.pending, *l.eof)
+			}
+			l.ready = len(l.pending)
+		}
+	}
+
+	l.next++
+
+	return l.pending[l.next-1]
+}
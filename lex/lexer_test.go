@@ -0,0 +1,68 @@
+package lex
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"../tokens"
+)
+
+// Benchmarks of each implementation in both modes, lexing a generated source
+// of about 4 MB. Run with make bench.
+
+// Statements making up generated sources, in the proportions of typical code
+var statements = []string {
+	"var total = total + prices[i] * 2\n",
+	"if count > 10 { println(\"too many: ${count}\") } else { count = count + 1 }\n",
+	"for var i = 0; i < len(items); var i = i + 1 { process(items[i], 0x1F, 1.5e-3) }\n",
+	"const greeting = \"Hello, World!\\n\"\n",
+	"// Works out the next value from the previous ones\n",
+	"/// Doc comment for the function below\n",
+	"var names = [\"alice\", \"bob\", \"charlie\"]\n",
+	"select { case var v = ch.receive() println(v) default println(\"none\") }\n",
+	"test \"adds numbers\" { assertEqual(add(1_000, 2), 1_002) }\n",
+	"var naïve = résumé.größe / 3.0\n",
+	"\n",
+}
+
+// generate returns a source of about size bytes of random statements.
+func generate(size int) []byte {
+	r := rand.New(rand.NewSource(1))
+
+	var b bytes.Buffer
+	for b.Len() < size {
+		b.WriteString(statements[r.Intn(len(statements))])
+	}
+
+	return b.Bytes()
+}
+
+func benchmark(b *testing.B, options Options) {
+	source := generate(4 << 20)
+	b.SetBytes(int64(len(source)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		l := New(bytes.NewReader(source), options)
+		for t := l.Next(); t.Type != tokens.EOF; t = l.Next() {
+		}
+	}
+}
+
+func BenchmarkMatchers(b *testing.B) {
+	benchmark(b, Options{Implementation: Matchers})
+}
+
+func BenchmarkDFA(b *testing.B) {
+	benchmark(b, Options{Implementation: DFA})
+}
+
+func BenchmarkMatchersLossless(b *testing.B) {
+	benchmark(b, Options{Implementation: Matchers, Lossless: true})
+}
+
+func BenchmarkDFALossless(b *testing.B) {
+	benchmark(b, Options{Implementation: DFA, Lossless: true})
+}
@@ -0,0 +1,550 @@
+package lex
+
+import (
+	"fmt"
+	"io"
+	"unicode"
+	"unicode/utf8"
+	"strings"
+
+	"../tokens"
+	"../location"
+)
+
+var operatorCharset string = "+-*/<>="
+var operatorToToken map[string] tokens.TokenType = map[string] tokens.TokenType {
+	"+": tokens.Add,
+	"++": tokens.Increment,
+	"-": tokens.Subtract,
+	"--": tokens.Decrement,
+	"*": tokens.Multiply,
+	"/": tokens.Divide,
+	"<": tokens.LessThan,
+	">": tokens.GreaterThan,
+	"=": tokens.Assignment,
+	"==": tokens.EqualTo,
+}
+
+var separatorCharset string = ";,.(){}[]"
+
+var separatorToToken map[string] tokens.TokenType = map[string] tokens.TokenType {
+	";": tokens.Semicolon,
+	",": tokens.Comma,
+	".": tokens.Dot,
+	"(": tokens.OpenBracket,
+	")": tokens.CloseBracket,
+	"{": tokens.OpenCurlyBracket,
+	"}": tokens.CloseCurlyBracket,
+	"[": tokens.OpenSquareBracket,
+	"]": tokens.CloseSquareBracket,
+}
+
+// fixedToken is the type and literal of a token always spelled the same.
+type fixedToken struct {
+	tokenType tokens.TokenType
+	literal string
+}
+
+// Operators and separators by their rune, doubled operators such as "++" by
+// the rune they double, and keywords by their length, so they're looked up
+// without hashing and tokens share their literals. Generated in init from
+// the maps above and tokens.Keywords.
+var runeTokens, doubledTokens [utf8.RuneSelf]fixedToken
+var keywordsByLength [][]fixedToken
+
+type matcher interface {
+	isMatch(first rune) bool
+	match(s *matcherScanner)
+}
+
+var matchers []matcher
+
+// Matcher of each ASCII rune, nil if none match it
+var asciiMatchers [utf8.RuneSelf]matcher
+
+// ASCII runes skipped in bulk between tokens and within identifiers
+var blanks, asciiIdentifierContinue [utf8.RuneSelf]bool
+
+func init() {
+	matchers = []matcher {
+		stringMatcher{},
+		numberMatcher{},
+		operatorMatcher{},
+		separatorMatcher{},
+		keywordIdentifierMatcher{},
+	}
+
+	for r := range asciiMatchers {
+		asciiMatchers[r] = matcherOf(rune(r))
+		asciiIdentifierContinue[r] = tokens.IsIdentifierContinue(rune(r))
+	}
+	blanks[' '], blanks['\t'], blanks['\r'] = true, true, true
+
+	for _, m := range []map[string] tokens.TokenType{operatorToToken, separatorToToken} {
+		for literal, tokenType := range m {
+			if len(literal) == 1 {
+				runeTokens[literal[0]] = fixedToken{tokenType, literal}
+			} else {
+				doubledTokens[literal[0]] = fixedToken{tokenType, literal}
+			}
+		}
+	}
+
+	for literal, tokenType := range tokens.Keywords {
+		for len(keywordsByLength) <= len(literal) {
+			keywordsByLength = append(keywordsByLength, nil)
+		}
+		keywordsByLength[len(literal)] = append(keywordsByLength[len(literal)], fixedToken{tokenType, literal})
+	}
+}
+
+// matcherOf returns the first matcher matching r, or nil.
+func matcherOf(r rune) matcher {
+	if r < utf8.RuneSelf {
+		if m := asciiMatchers[r]; m != nil {
+			return m
+		}
+	}
+
+	for _, m := range matchers {
+		if m.isMatch(r) {
+			return m
+		}
+	}
+
+	return nil
+}
+
+// matcherScanner lexes by trying each matcher on the current rune, reading
+// the source only as far as needed for the current token.
+type matcherScanner struct {
+	rq *byteQueue
+	// Tokens found by the current step
+	found []tokens.Token
+	// Identifiers and number literals read lately, see intern
+	interned [internedSize]internedString
+	// Brace depth inside each interpolated expression being lexed,
+	// innermost last.
+	interpolations []int
+}
+
+// Number of strings kept by intern, a power of 2
+const internedSize = 512
+
+type internedString struct {
+	source string
+	value string
+}
+
+// Most tokens found in a step
+const maxFound = 64
+
+func newMatcherScanner(reader io.Reader, fileName string) *matcherScanner {
+	return &matcherScanner {
+		rq: newByteQueue(reader, fileName),
+		interpolations: make([]int, 0),
+	}
+}
+
+// emit adds a token from start to the current rune.
+func (s *matcherScanner) emit(tokenType tokens.TokenType, literal string, start position) {
+	s.found = addToken(s.found, tokenType, literal, s.rq.file, start, s.rq.position())
+}
+
+// errorf adds an Error token from start to the current rune.
+func (s *matcherScanner) errorf(start position, format string, args ...interface{}) {
+	s.emit(tokens.Error, fmt.Sprintf(format, args...), start)
+}
+
+// intern returns source as a string normalized to NFC, reusing the string
+// returned for the same source when it was read lately, so names used
+// over and over are only allocated and normalized once. Strings are kept
+// in a table indexed by a hash of their source, each replacing the last
+// with the same hash.
+func (s *matcherScanner) intern(source []byte) string {
+	h := uint32(2166136261)
+	for _, b := range source {
+		h = (h ^ uint32(b)) * 16777619
+	}
+
+	kept := &s.interned[h % internedSize]
+	if kept.source != string(source) {
+		kept.source = string(source)
+		kept.value = tokens.NFC(kept.source)
+	}
+
+	return kept.value
+}
+
+// step finds the token at the current rune, then carries on while the
+// next ones start well within the source already read, so several are found
+// at a time without waiting for more of the source. Only a token longer
+// than minRead may be waited for.
+func (s *matcherScanner) step(found []tokens.Token) ([]tokens.Token, bool) {
+	s.found = found
+
+	more := s.scan()
+	for more && len(s.found) - len(found) < maxFound && s.rq.buffered() > minRead {
+		more = s.scan()
+	}
+
+	return s.found, more
+}
+
+// scan matches the token at the current rune and reports whether there is
+// more of the source.
+func (s *matcherScanner) scan() bool {
+	rq := s.rq
+
+	r, done := rq.current()
+	if done {
+		if len(s.interpolations) > 0 {
+			s.errorf(rq.position(), "Unterminated string literal, unexpected EOF in interpolation")
+			s.interpolations = s.interpolations[:0]
+		}
+		if err := rq.readError(); err != nil {
+			s.errorf(rq.position(), "Failed to read source, %s", err)
+		}
+		return false
+	}
+
+	// White space is skipped here as it's most of the runes between tokens
+	for r == ' ' || r == '\t' || r == '\n' || r == '\r' {
+		if r == '\n' {
+			rq.next()
+		}
+		rq.skip(&blanks)
+		if r, done = rq.current(); done {
+			return true
+		}
+	}
+
+	rq.start()
+
+	// Checked first so a '}' in a comment doesn't end an interpolation
+	if isComment(rq) {
+		matchComment(s)
+		return true
+	}
+
+	if top := len(s.interpolations) - 1; top >= 0 {
+		if r == '{' {
+			s.interpolations[top]++
+		} else if r == '}' && s.interpolations[top] > 0 {
+			s.interpolations[top]--
+		} else if r == '}' {
+			// End of the interpolated expression, carry on with the string
+			s.interpolations = s.interpolations[:top]
+			matchStringPart(s, tokens.InterpolationEnd, tokens.InterpolationMiddle)
+
+			return true
+		}
+	}
+
+	if m := matcherOf(r); m != nil {
+		m.match(s)
+		return true
+	}
+
+	start := rq.position()
+	rq.next()
+
+	if !unicode.IsSpace(r) {
+		s.errorf(start, "Unexpected character %q", r)
+	}
+
+	return true
+}
+
+func (s *matcherScanner) end() location.Location {
+	p := s.rq.position()
+	return span(s.rq.file, p, p)
+}
+
+type stringMatcher struct {}
+
+func (sm stringMatcher) isMatch(r rune) bool {
+	return r == '"'
+}
+
+func (sm stringMatcher) match(s *matcherScanner) {
+	matchStringPart(s, tokens.StringLiteral, tokens.InterpolationStart)
+}
+
+// Runes a string literal is scanned up to, the rest are skipped in bulk
+var stringStops, lineEnd [utf8.RuneSelf]bool
+
+func init() {
+	stringStops['"'], stringStops['\\'], stringStops['$'], stringStops['\n'] = true, true, true, true
+	lineEnd['\n'] = true
+}
+
+// matchStringPart matches a piece of a string literal starting at its opening
+// delimiter, either the opening quote or the '}' closing an interpolated
+// expression. The emitted token is of type end if the piece is closed by a
+// quote and of type interpolation if it is closed by "${". A piece with an
+// invalid escape sequence is emitted undecoded followed by an Error token
+// spanning the escape, so the interpolations around it are still followed.
+func matchStringPart(s *matcherScanner, end tokens.TokenType, interpolation tokens.TokenType) {
+	rq := s.rq
+	open, _ := rq.current()
+	start := rq.position()
+	tokenType := end
+
+	// The piece without its delimiters, sliced once its end is found
+	var body []byte
+
+	rq.next()
+	for {
+		r, done := rq.skipUntil(&stringStops)
+		if done {
+			s.errorf(start, "Unterminated string literal")
+			return
+		}
+
+		if r == '"' {
+			body = rq.token()[1:]
+			break
+		}
+
+		// Escaped char, decoded below
+		if r == '\\' {
+			if _, done = rq.next(); done {
+				s.errorf(start, "Unterminated string literal")
+				return
+			}
+		} else if r == '$' {
+			if next, _ := rq.peek(); next == '{' {
+				body = rq.token()[1:]
+				rq.next()
+				tokenType = interpolation
+				s.interpolations = append(s.interpolations, 0)
+				break
+			}
+		}
+		rq.next()
+	}
+
+	raw := string(body)
+	rq.next()
+
+	value, escStart, escEnd, err := tokens.UnescapeString(raw)
+	if err != nil {
+		// Each byte of invalid UTF-8 reads as U+FFFD, as when unescaped
+		s.emit(tokenType, string([]rune(raw)), start)
+
+		at := span(rq.file, start, start).Advance(string(open)+raw[:escStart])
+		s.found = append(s.found, tokens.Errorf(at.To(at.Advance(raw[escStart:escEnd])), "Invalid string literal, %s", err))
+		return
+	}
+
+	s.emit(tokenType, value, start)
+}
+
+// isComment reports whether a comment starts at the current rune of rq.
+func isComment(rq *byteQueue) bool {
+	if r, _ := rq.current(); r != '/' {
+		return false
+	}
+
+	next, _ := rq.peek()
+	return next == '/' || next == '*'
+}
+
+// matchComment skips a line comment, or a block comment which may contain
+// nested block comments. A doc comment, a line comment starting with exactly
+// three slashes, is emitted as a token holding the rest of its line.
+func matchComment(s *matcherScanner) {
+	rq := s.rq
+	start := rq.position()
+	rq.next()
+
+	if r, _ := rq.current(); r == '/' {
+		rq.skipUntil(&lineEnd)
+
+		// Without the leading "//"
+		text := rq.token()[2:]
+		if len(text) > 0 && text[0] == '/' && (len(text) == 1 || text[1] != '/') {
+			s.emit(tokens.DocComment, validString(text[1:]), start)
+		}
+
+		return
+	}
+
+	depth := 1
+	for r, done := rq.next(); depth > 0; r, done = rq.current() {
+		if done {
+			s.errorf(start, "Unterminated block comment")
+			return
+		}
+
+		if next, _ := rq.peek(); r == '/' && next == '*' {
+			depth++
+			rq.next()
+		} else if r == '*' && next == '/' {
+			depth--
+			rq.next()
+		}
+		rq.next()
+	}
+}
+
+// validString returns b as a string, with each byte of invalid UTF-8 read as
+// U+FFFD.
+func validString(b []byte) string {
+	if utf8.Valid(b) {
+		return string(b)
+	}
+
+	return string([]rune(string(b)))
+}
+
+type numberMatcher struct {}
+
+// ASCII runes which may belong to a number literal, bar signs, skipped in
+// bulk
+var asciiNumberContinue [utf8.RuneSelf]bool
+
+func init() {
+	for r := range asciiNumberContinue {
+		asciiNumberContinue[r] = r == '_' || r == '.' || unicode.IsLetter(rune(r)) || unicode.IsDigit(rune(r))
+	}
+}
+
+// A '.' is matched as well in case it starts a float like .5, if it doesn't
+// it is emitted as a Dot.
+func (nm numberMatcher) isMatch(r rune) bool {
+	return unicode.IsDigit(r) || r == '.'
+}
+
+// match reads every rune that could belong to the literal, so e.g. 1.2.3 or
+// 12ab is reported as one malformed literal rather than split into several
+// tokens, then checks it.
+func (nm numberMatcher) match(s *matcherScanner) {
+	rq := s.rq
+	r, _ := rq.current()
+	start := rq.position()
+
+	if next, _ := rq.peek(); r == '.' && !unicode.IsDigit(next) {
+		rq.next()
+		s.emit(tokens.Dot, ".", start)
+		return
+	}
+
+	// In hex literals e is a digit, elsewhere it may be followed by the
+	// sign of the exponent
+	next, _ := rq.peek()
+	hex := r == '0' && (next == 'x' || next == 'X')
+
+	ascii := r < utf8.RuneSelf
+	rq.next()
+	for {
+		rq.skip(&asciiNumberContinue)
+
+		r, done := rq.current()
+		if done {
+			break
+		}
+
+		// Only an ASCII rune stops the skip
+		token := rq.token()
+		last := token[len(token)-1]
+		isSign := (r == '+' || r == '-') && (last == 'e' || last == 'E') && !hex
+
+		if !isSign && (r < utf8.RuneSelf || !unicode.IsLetter(r) && !unicode.IsDigit(r)) {
+			break
+		}
+		ascii = ascii && r < utf8.RuneSelf
+		rq.next()
+	}
+
+	// Valid literals are ASCII, which NFC leaves as it is
+	var literal string
+	if ascii {
+		literal = s.intern(rq.token())
+	} else {
+		literal = string(rq.token())
+	}
+
+	tokenType, err := tokens.NumberType(literal)
+	if err != nil {
+		s.emit(tokens.Error, err.Error(), start)
+		return
+	}
+
+	s.emit(tokenType, literal, start)
+}
+
+type operatorMatcher struct {}
+
+func (om operatorMatcher) isMatch(r rune) bool {
+	return strings.ContainsRune(operatorCharset, r)
+}
+
+func (om operatorMatcher) match(s *matcherScanner) {
+	rq := s.rq
+	r, _ := rq.current()
+	start := rq.position()
+
+	fixed := runeTokens[r]
+	if next, _ := rq.next(); next == r && doubledTokens[r].tokenType != 0 {
+		rq.next()
+		fixed = doubledTokens[r]
+	}
+
+	s.emit(fixed.tokenType, fixed.literal, start)
+}
+
+type separatorMatcher struct {}
+
+func (sm separatorMatcher) isMatch(r rune) bool {
+	return strings.ContainsRune(separatorCharset, r)
+}
+
+func (sm separatorMatcher) match(s *matcherScanner) {
+	rq := s.rq
+	r, _ := rq.current()
+	start := rq.position()
+	rq.next()
+
+	fixed := runeTokens[r]
+	s.emit(fixed.tokenType, fixed.literal, start)
+}
+
+type keywordIdentifierMatcher struct {}
+
+func (km keywordIdentifierMatcher) isMatch(r rune) bool {
+	return tokens.IsIdentifierStart(r)
+}
+
+func (km keywordIdentifierMatcher) match(s *matcherScanner) {
+	rq := s.rq
+	start := rq.position()
+
+	rq.next()
+	rq.skip(&asciiIdentifierContinue)
+	for r, done := rq.current(); !done && isIdentifierContinue(r); r, done = rq.next() {
+	}
+
+	source := rq.token()
+	if len(source) < len(keywordsByLength) {
+		for _, keyword := range keywordsByLength[len(source)] {
+			if source[0] == keyword.literal[0] && string(source) == keyword.literal {
+				s.emit(keyword.tokenType, keyword.literal, start)
+				return
+			}
+		}
+	}
+
+	s.emit(tokens.Identifier, s.intern(source), start)
+}
+
+// isIdentifierContinue is tokens.IsIdentifierContinue with a shortcut for
+// ASCII, which most identifiers are.
+func isIdentifierContinue(r rune) bool {
+	if r < utf8.RuneSelf {
+		return asciiIdentifierContinue[r]
+	}
+
+	return tokens.IsIdentifierContinue(r)
+}
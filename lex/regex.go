@@ -0,0 +1,349 @@
+package lex
+
+import (
+	"fmt"
+	"sort"
+	"unicode"
+)
+
+// Regular expressions for token specs, compiled to an nfa by Thompson's
+// construction. The syntax is a subset of the usual one: literal runes, \
+// escaping any punctuation, . for any rune, classes like [a-z] or [^"\\],
+// grouping, |, *, + and ?. \d matches decimal digits, \s white space and
+// \pL or \p{Name} a unicode category or property, all also inside classes.
+// In a class -- removes the runes that follow it, e.g. [\pL--xX].
+
+// runeRange is the runes from lo to hi inclusive.
+type runeRange struct {
+	lo, hi rune
+}
+
+// runeSet is a set of runes as sorted ranges which neither overlap nor touch.
+type runeSet []runeRange
+
+// newRuneSet returns the set of the runes in ranges, which may be in any
+// order and overlap.
+func newRuneSet(ranges ...runeRange) runeSet {
+	sorted := append([]runeRange{}, ranges...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].lo < sorted[j].lo
+	})
+
+	set := runeSet{}
+	for _, r := range sorted {
+		if n := len(set); n > 0 && r.lo <= set[n-1].hi+1 {
+			if r.hi > set[n-1].hi {
+				set[n-1].hi = r.hi
+			}
+		} else {
+			set = append(set, r)
+		}
+	}
+
+	return set
+}
+
+func (s runeSet) union(o runeSet) runeSet {
+	return newRuneSet(append(append([]runeRange{}, s...), o...)...)
+}
+
+func (s runeSet) complement() runeSet {
+	c := runeSet{}
+	next := rune(0)
+
+	for _, r := range s {
+		if r.lo > next {
+			c = append(c, runeRange{next, r.lo-1})
+		}
+		next = r.hi+1
+	}
+
+	if next <= unicode.MaxRune {
+		c = append(c, runeRange{next, unicode.MaxRune})
+	}
+
+	return c
+}
+
+func (s runeSet) minus(o runeSet) runeSet {
+	return s.complement().union(o).complement()
+}
+
+// tableSet returns the runes of a unicode table.
+func tableSet(t *unicode.RangeTable) runeSet {
+	ranges := []runeRange{}
+
+	add := func(lo rune, hi rune, stride rune) {
+		if stride == 1 {
+			ranges = append(ranges, runeRange{lo, hi})
+			return
+		}
+
+		for r := lo; r <= hi; r += stride {
+			ranges = append(ranges, runeRange{r, r})
+		}
+	}
+
+	for _, r := range t.R16 {
+		add(rune(r.Lo), rune(r.Hi), rune(r.Stride))
+	}
+	for _, r := range t.R32 {
+		add(rune(r.Lo), rune(r.Hi), rune(r.Stride))
+	}
+
+	return newRuneSet(ranges...)
+}
+
+// nfa is a nondeterministic automaton, its states are referred to by index.
+type nfa struct {
+	states []nfaState
+}
+
+// nfaState moves to next on a rune in set, if next isn't -1, and to each of
+// epsilon without reading a rune. accept is the index of the spec whose
+// pattern ends at the state, or -1.
+type nfaState struct {
+	set runeSet
+	next int
+	epsilon []int
+	accept int
+}
+
+func (n *nfa) addState() int {
+	n.states = append(n.states, nfaState {
+		next: -1,
+		accept: -1,
+	})
+
+	return len(n.states)-1
+}
+
+func (n *nfa) addEpsilon(from int, to ...int) {
+	n.states[from].epsilon = append(n.states[from].epsilon, to...)
+}
+
+// fragment is the part of an nfa matching a piece of a pattern, from start
+// to end.
+type fragment struct {
+	start, end int
+}
+
+type regexParser struct {
+	pattern []rune
+	i int
+	*nfa
+}
+
+// compileRegex adds the states matching pattern to n.
+func compileRegex(n *nfa, pattern string) fragment {
+	p := &regexParser {
+		pattern: []rune(pattern),
+		nfa: n,
+	}
+
+	f := p.parseAlternation()
+	if p.i < len(p.pattern) {
+		p.fail("unexpected %q", p.pattern[p.i])
+	}
+
+	return f
+}
+
+func (p *regexParser) fail(format string, args ...interface{}) {
+	panic(fmt.Sprintf("Invalid token pattern %q at offset %d, %s", string(p.pattern), p.i, fmt.Sprintf(format, args...)))
+}
+
+func (p *regexParser) peek() (rune, bool) {
+	if p.i < len(p.pattern) {
+		return p.pattern[p.i], true
+	}
+
+	return 0, false
+}
+
+func (p *regexParser) next() rune {
+	r, ok := p.peek()
+	if !ok {
+		p.fail("unexpected end of pattern")
+	}
+	p.i++
+
+	return r
+}
+
+func (p *regexParser) parseAlternation() fragment {
+	f := p.parseConcatenation()
+
+	for r, ok := p.peek(); ok && r == '|'; r, ok = p.peek() {
+		p.i++
+		other := p.parseConcatenation()
+
+		alternation := fragment{p.addState(), p.addState()}
+		p.addEpsilon(alternation.start, f.start, other.start)
+		p.addEpsilon(f.end, alternation.end)
+		p.addEpsilon(other.end, alternation.end)
+		f = alternation
+	}
+
+	return f
+}
+
+func (p *regexParser) parseConcatenation() fragment {
+	state := p.addState()
+	f := fragment{state, state}
+
+	for r, ok := p.peek(); ok && r != '|' && r != ')'; r, ok = p.peek() {
+		next := p.parseRepetition()
+		p.addEpsilon(f.end, next.start)
+		f.end = next.end
+	}
+
+	return f
+}
+
+func (p *regexParser) parseRepetition() fragment {
+	f := p.parseAtom()
+
+	for r, ok := p.peek(); ok && (r == '*' || r == '+' || r == '?'); r, ok = p.peek() {
+		p.i++
+
+		repeated := fragment{p.addState(), p.addState()}
+		p.addEpsilon(repeated.start, f.start)
+		p.addEpsilon(f.end, repeated.end)
+		if r != '+' {
+			p.addEpsilon(repeated.start, repeated.end)
+		}
+		if r != '?' {
+			p.addEpsilon(f.end, f.start)
+		}
+		f = repeated
+	}
+
+	return f
+}
+
+func (p *regexParser) parseAtom() fragment {
+	var set runeSet
+
+	switch r := p.next(); r {
+	case '(':
+		f := p.parseAlternation()
+		if p.next() != ')' {
+			p.fail("missing )")
+		}
+		return f
+	case '*', '+', '?':
+		p.i--
+		p.fail("nothing to repeat")
+	case '[':
+		set = p.parseClass()
+	case '.':
+		set = runeSet{{0, unicode.MaxRune}}
+	case '\\':
+		set = p.parseEscape()
+	default:
+		set = runeSet{{r, r}}
+	}
+
+	f := fragment{p.addState(), p.addState()}
+	p.states[f.start].set = set
+	p.states[f.start].next = f.end
+
+	return f
+}
+
+// parseClass parses a class after its opening '['.
+func (p *regexParser) parseClass() runeSet {
+	negated := false
+	if r, _ := p.peek(); r == '^' {
+		negated = true
+		p.i++
+	}
+
+	set := p.parseClassItems()
+	if r, _ := p.peek(); r == '-' && p.i+1 < len(p.pattern) && p.pattern[p.i+1] == '-' {
+		p.i += 2
+		set = set.minus(p.parseClassItems())
+	}
+
+	if p.next() != ']' {
+		p.fail("missing ]")
+	}
+
+	if negated {
+		return set.complement()
+	}
+
+	return set
+}
+
+// parseClassItems parses runes, ranges and escapes up to the end of a class
+// or a "--".
+func (p *regexParser) parseClassItems() runeSet {
+	set := runeSet{}
+
+	for r, ok := p.peek(); ok && r != ']'; r, ok = p.peek() {
+		if r == '-' && p.i+1 < len(p.pattern) && p.pattern[p.i+1] == '-' {
+			break
+		}
+		p.i++
+
+		if r == '\\' {
+			set = set.union(p.parseEscape())
+			continue
+		}
+
+		hi := r
+		if next, _ := p.peek(); next == '-' && p.i+1 < len(p.pattern) && p.pattern[p.i+1] != ']' && p.pattern[p.i+1] != '-' {
+			p.i++
+			hi = p.next()
+			if hi == '\\' {
+				hi = p.next()
+			}
+			if hi < r {
+				p.fail("invalid range %q-%q", r, hi)
+			}
+		}
+		set = set.union(runeSet{{r, hi}})
+	}
+
+	return set
+}
+
+// parseEscape parses an escape after its '\'.
+func (p *regexParser) parseEscape() runeSet {
+	switch r := p.next(); r {
+	case 'd':
+		return tableSet(unicode.Digit)
+	case 's':
+		return tableSet(unicode.White_Space)
+	case 'n':
+		return runeSet{{'\n', '\n'}}
+	case 't':
+		return runeSet{{'\t', '\t'}}
+	case 'r':
+		return runeSet{{'\r', '\r'}}
+	case 'p':
+		name := string(p.next())
+		if name == "{" {
+			name = ""
+			for r := p.next(); r != '}'; r = p.next() {
+				name += string(r)
+			}
+		}
+
+		if t, exists := unicode.Categories[name]; exists {
+			return tableSet(t)
+		} else if t, exists := unicode.Properties[name]; exists {
+			return tableSet(t)
+		}
+		p.fail("unknown unicode class %q", name)
+	default:
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			p.fail("unknown escape \\%c", r)
+		}
+		return runeSet{{r, r}}
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"strings"
+
+	"../lex"
+	"../tokens"
+)
+
+// Pieces of random sources, chosen to cover every kind of token and of bad
+// input, and the places where the lexers could disagree
+var fragments = []string {
+	"for", "format", "if", "iffy", "else", "return", "break", "continue", "var", "const",
+	"import", "go", "select", "case", "default", "test", "true", "false", "trueish",
+	"x", "_y", "_9", "héllo", "\u00e9t\u00e9", "e\u0301te\u0301", "日本", "ⅷ", "℘x", "a·b", "x\u200d",
+	"$a", "@b", "!", "#", "§", "€", "🙂", "\x01", "\xff", "\u0301", "١٢",
+	"1", "0", "42", "0x1F", "0o17", "0b101", "0b2", "1_000", "1__0", "1.5e-3", "15e3", "1e-3", ".5", "5.", "1e", "1.2.3", "12ab",
+	`"a$b"`, `"$"`, `"$$"`, `"a${x}b"`, `"${ "${1}" }"`, `"a${ {} }b${y}c"`, `"\n\$é"`, `"\q"`, `"a b"`, `"unterminated`, `"${`, `"\`,
+	"++", "--", "==", "=", "+", "-", "*", "/", "<", ">", ";", ",", ".", "(", ")", "{", "}", "[", "]",
+	"// c\n", "/// doc\n", "////\n", "/* x /* y */ */", "/* open", "/**/",
+	" ", "\t", "\n", "\r\n", "\u00a0",
+}
+
+func main() {
+	n := flag.Int("n", 1000, "number of random sources to check")
+	seed := flag.Int64("seed", 1, "seed of the random sources")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] [files...]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Checks the lexer's implementations give the same tokens, in both modes, for each file and for random sources, and that lossless tokens rebuild their source.\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	failed := 0
+	for _, fileName := range flag.Args() {
+		buff, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed++
+			continue
+		}
+
+		if !check(fileName, string(buff)) {
+			failed++
+		}
+	}
+
+	r := rand.New(rand.NewSource(*seed))
+	for i := 0; i < *n; i++ {
+		var b strings.Builder
+		for j := r.Intn(80); j > 0; j-- {
+			b.WriteString(fragments[r.Intn(len(fragments))])
+			b.WriteString([]string{"", " ", "\n"}[r.Intn(3)])
+		}
+
+		if !check(fmt.Sprintf("random source %d", i), b.String()) {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d sources failed\n", failed)
+		os.Exit(1)
+	}
+	fmt.Printf("%d sources passed\n", len(flag.Args()) + *n)
+}
+
+// check reports whether both implementations give the same tokens for
+// source, printing the first difference and the source if they don't.
+func check(name string, source string) bool {
+	for _, lossless := range []bool{false, true} {
+		matched := lexAll(source, lex.Matchers, lossless)
+		automated := lexAll(source, lex.DFA, lossless)
+
+		for i := 0; i < len(matched) || i < len(automated); i++ {
+			if i >= len(matched) || i >= len(automated) || matched[i] != automated[i] {
+				fmt.Fprintf(os.Stderr, "%s: token %d differs, lossless %t\n", name, i, lossless)
+				fmt.Fprintf(os.Stderr, "matchers: %s\n", tokenAt(matched, i))
+				fmt.Fprintf(os.Stderr, "dfa:      %s\n", tokenAt(automated, i))
+				fmt.Fprintf(os.Stderr, "source: %q\n", source)
+				return false
+			}
+		}
+
+		if !lossless {
+			continue
+		}
+
+		var rebuilt strings.Builder
+		for _, t := range matched {
+			rebuilt.WriteString(t.Leading + t.Text + t.Trailing)
+		}
+		if rebuilt.String() != source {
+			fmt.Fprintf(os.Stderr, "%s: lossless tokens rebuild %q\n", name, rebuilt.String())
+			fmt.Fprintf(os.Stderr, "source: %q\n", source)
+			return false
+		}
+	}
+
+	return true
+}
+
+// lexAll returns the tokens of source up to and including the first EOF.
+func lexAll(source string, implementation lex.Implementation, lossless bool) []tokens.Token {
+	l := lex.New(strings.NewReader(source), lex.Options {
+		FileName: "source",
+		Implementation: implementation,
+		Lossless: lossless,
+	})
+
+	lexed := make([]tokens.Token, 0)
+	for t := l.Next(); ; t = l.Next() {
+		lexed = append(lexed, t)
+		if t.Type == tokens.EOF {
+			return lexed
+		}
+	}
+}
+
+func tokenAt(lexed []tokens.Token, i int) string {
+	if i >= len(lexed) {
+		return "none"
+	}
+
+	return lexed[i].LosslessString()
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"../nodes"
+	"../tokens"
+)
+
+// The linker resolves every Import in an ast, running the lexer and parser
+// on the imported file and adding the result as the body of the Import.
+
+type linker struct {
+	searchPath searchPath
+	lexer string
+	parser string
+	// Absolute paths of the files currently being linked, outermost first
+	linking []string
+	// Imports of each file being linked by namespace, as linking
+	namespaces []map[string] *nodes.Import
+	// Linked bodies of files already imported, by absolute path
+	linked map[string] *nodes.Program
+}
+
+func newLinker() *linker {
+	return &linker {
+		searchPath: make(searchPath, 0),
+		linking: make([]string, 0),
+		namespaces: make([]map[string] *nodes.Import, 0),
+		linked: make(map[string] *nodes.Program),
+	}
+}
+
+// link resolves the imports in p, which was parsed from the file named file.
+func (l *linker) link(file string, p *nodes.Program) error {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return err
+	}
+
+	l.linking = append(l.linking, abs)
+	l.namespaces = append(l.namespaces, make(map[string] *nodes.Import))
+	defer func() {
+		l.linking = l.linking[:len(l.linking)-1]
+		l.namespaces = l.namespaces[:len(l.namespaces)-1]
+	}()
+
+	for _, s := range p.Statements {
+		if err := l.linkStatement(file, s); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (l *linker) linkStatement(file string, s nodes.Statement) error {
+	switch s := s.(type) {
+	case *nodes.If:
+		if err := l.linkStatement(file, s.Primary); err != nil {
+			return err
+		}
+		if s.Alternative != nil {
+			return l.linkStatement(file, s.Alternative)
+		}
+	case *nodes.For:
+		if err := l.linkStatement(file, s.PreStatement); err != nil {
+			return err
+		}
+		if err := l.linkStatement(file, s.PostStatement); err != nil {
+			return err
+		}
+		return l.linkStatement(file, s.Loop)
+	case *nodes.Scope:
+		for _, statement := range s.Statements {
+			if err := l.linkStatement(file, statement); err != nil {
+				return err
+			}
+		}
+	case *nodes.Select:
+		for _, c := range s.Cases {
+			if err := l.linkStatement(file, c.Body); err != nil {
+				return err
+			}
+		}
+		if s.Default != nil {
+			return l.linkStatement(file, s.Default)
+		}
+	case *nodes.Test:
+		return l.linkStatement(file, s.Body)
+	case *nodes.Import:
+		return l.linkImport(file, s)
+	}
+
+	return nil
+}
+
+func (l *linker) linkImport(file string, i *nodes.Import) error {
+	path, err := l.resolve(file, i.Path)
+	if err != nil {
+		return fmt.Errorf("Failed to import %q at %s: %s", i.Path, i.Location, err)
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	for n, linking := range l.linking {
+		if linking == abs {
+			cycle := append(append([]string{}, l.linking[n:]...), abs)
+			return fmt.Errorf("Import cycle at %s: %s", i.Location, strings.Join(cycle, " -> "))
+		}
+	}
+
+	i.Path = path
+
+	// A file's imports share one namespace whatever scope they're in
+	namespace := i.Namespace()
+	if !tokens.IsName(namespace) {
+		return fmt.Errorf("Cannot import %q at %s, its namespace %q is not an identifier", path, i.Location, namespace)
+	}
+
+	namespaces := l.namespaces[len(l.namespaces)-1]
+	if other, exists := namespaces[namespace]; exists && !samePath(other.Path, abs) {
+		return fmt.Errorf("Import of %q at %s reuses namespace %q of the import of %q at %s", path, i.Location, namespace, other.Path, other.Location)
+	}
+	namespaces[namespace] = i
+
+	if body, exists := l.linked[abs]; exists {
+		i.Body = body
+		return nil
+	}
+
+	body, err := l.parse(path)
+	if err != nil {
+		return fmt.Errorf("Failed to import %q at %s: %s", path, i.Location, err)
+	}
+
+	if err := l.link(path, body); err != nil {
+		return err
+	}
+
+	l.linked[abs] = body
+	i.Body = body
+
+	return nil
+}
+
+// samePath reports whether path is the file at the absolute path abs.
+func samePath(path string, abs string) bool {
+	otherAbs, err := filepath.Abs(path)
+	return err == nil && otherAbs == abs
+}
+
+// resolve finds the file imported as path from the file named file. Relative
+// paths are looked up next to the importing file, then in the search path.
+func (l *linker) resolve(file string, path string) (string, error) {
+	if filepath.IsAbs(path) {
+		return path, nil
+	}
+
+	candidates := []string{filepath.Join(filepath.Dir(file), path)}
+	for _, dir := range l.searchPath {
+		candidates = append(candidates, filepath.Join(dir, path))
+	}
+
+	for _, c := range candidates {
+		if info, err := os.Stat(c); err == nil && !info.IsDir() {
+			return c, nil
+		}
+	}
+
+	return "", fmt.Errorf("file not found in %s", strings.Join(candidates, ", "))
+}
+
+// parse runs the lexer and parser on the file at path.
+func (l *linker) parse(path string) (*nodes.Program, error) {
+	lexer := exec.Command(l.lexer, path)
+	parser := exec.Command(l.parser)
+
+	lexed, err := lexer.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed bytes.Buffer
+	parser.Stdin = lexed
+	parser.Stdout = &parsed
+	lexer.Stderr = os.Stderr
+	parser.Stderr = os.Stderr
+
+	if err := parser.Start(); err != nil {
+		return nil, err
+	}
+	if err := lexer.Run(); err != nil {
+		parser.Wait()
+		return nil, fmt.Errorf("%s: %s", l.lexer, err)
+	}
+	if err := parser.Wait(); err != nil {
+		return nil, fmt.Errorf("%s: %s", l.parser, err)
+	}
+
+	scanner := bufio.NewScanner(&parsed)
+	if !scanner.Scan() {
+		return nil, fmt.Errorf("empty file")
+	}
+
+	return nodes.ProgramFromScanner(scanner)
+}
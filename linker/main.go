@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"../nodes"
+)
+
+// searchPath is a repeatable flag listing directories to look for imports in.
+type searchPath []string
+
+func (sp *searchPath) String() string {
+	return strings.Join(*sp, string(filepath.ListSeparator))
+}
+
+func (sp *searchPath) Set(dir string) error {
+	*sp = append(*sp, dir)
+	return nil
+}
+
+func main() {
+	l := newLinker()
+
+	executable, err := os.Executable()
+	if err != nil {
+		panic(err)
+	}
+	root := filepath.Join(filepath.Dir(executable), "..")
+
+	flag.Var(&l.searchPath, "I", "directory to search for imported files, may be repeated")
+	flag.StringVar(&l.lexer, "lexer", filepath.Join(root, "lexer2", "lexer2"), "lexer used on imported files")
+	flag.StringVar(&l.parser, "parser", filepath.Join(root, "parser", "parser"), "parser used on imported files")
+	flag.Parse()
+
+	stdin := bufio.NewScanner(os.Stdin)
+
+	if stdin.Scan() {
+		p, err := nodes.ProgramFromScanner(stdin)
+		if err != nil {
+			panic(err)
+		}
+
+		if err := l.link(p.File, p); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		fmt.Println(p.String())
+	}
+}
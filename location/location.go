@@ -6,12 +6,22 @@ import (
 	"strconv"
 )
 
+// Location is a span of a source file, from its start to just after its
+// last rune. Offsets count bytes from the start of the file.
 type Location struct {
 	File string
 	Line uint
 	Column uint
+	Offset uint
+	EndLine uint
+	EndColumn uint
+	EndOffset uint
 }
 
+// Number of fields in an encoded location
+const EncodedFields = 7
+
+// String returns the file and the start of l, as used in messages.
 func (l Location) String() string {
 	// Replace all spaces in file name with zero width spaces
 	// Althought this kind of feels like a hack it's kind of
@@ -20,24 +30,94 @@ func (l Location) String() string {
 	return fmt.Sprintf("%s %d %d", l.File, l.Line, l.Column)
 }
 
+// Encode returns l with its span, as written in token and ast streams.
+func (l Location) Encode() string {
+	return fmt.Sprintf("%s %d %d %d %d", l, l.Offset, l.EndLine, l.EndColumn, l.EndOffset)
+}
+
+// Advance returns the empty span reached after reading s starting at l.
+func (l Location) Advance(s string) Location {
+	for _, r := range s {
+		if r == '\n' {
+			l.Line += 1
+			l.Column = 1
+		} else {
+			l.Column += 1
+		}
+	}
+	l.Offset += uint(len(s))
+
+	return l.To(l)
+}
+
+// To returns the span from the start of l to the start of end, e.g. the
+// position reached after reading a token.
+func (l Location) To(end Location) Location {
+	l.EndLine = end.Line
+	l.EndColumn = end.Column
+	l.EndOffset = end.Offset
+
+	return l
+}
+
+// Through returns the span from the start of l to the end of last.
+func (l Location) Through(last Location) Location {
+	l.EndLine = last.EndLine
+	l.EndColumn = last.EndColumn
+	l.EndOffset = last.EndOffset
+
+	return l
+}
+
+// LocationFromString parses an encoded location, or a file, line and
+// column, whose span is then empty.
 func LocationFromString(s string) (Location, error) {
 	l := Location{}
 	vals := strings.Split(s, " ")
-	if len(vals) != 3 {
+	if len(vals) != 3 && len(vals) != EncodedFields {
 		return l, fmt.Errorf("Failed to parse %q into Location", s)
 	}
 
-	// Replace all zero width spaces with spaces
-	l.File = strings.Join(vals[:len(vals)-2], string(rune(8203)))
+	l.File = vals[0]
+
+	numbers := make([]uint, len(vals)-1)
+	for i, v := range vals[1:] {
+		n, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return l, fmt.Errorf("Failed to parse %q into Location, %s", s, err)
+		}
+		numbers[i] = uint(n)
+	}
 
-	line, err := strconv.ParseUint(vals[1], 10, 32)
-	column, err := strconv.ParseUint(vals[2], 10, 32)
-	if err != nil {
-		return l, err
+	l.Line, l.Column = numbers[0], numbers[1]
+	if len(numbers) == 2 {
+		return l.To(l), nil
 	}
 
-	l.Line = uint(line)
-	l.Column = uint(column)
+	l.Offset, l.EndLine, l.EndColumn, l.EndOffset = numbers[2], numbers[3], numbers[4], numbers[5]
 
 	return l, nil
 }
+
+// SplitFields splits the location off the end of the space separated fields
+// of a token or ast line, whose other fields may contain spaces in quotes.
+func SplitFields(fields []string) ([]string, Location, error) {
+	n := 3
+	if len(fields) >= EncodedFields {
+		n = EncodedFields
+		for _, v := range fields[len(fields)-EncodedFields+1:] {
+			if _, err := strconv.ParseUint(v, 10, 32); err != nil {
+				n = 3
+				break
+			}
+		}
+	}
+
+	if len(fields) < n {
+		return nil, Location{}, fmt.Errorf("Failed to parse %q into Location", strings.Join(fields, " "))
+	}
+
+	l, err := LocationFromString(strings.Join(fields[len(fields)-n:], " "))
+
+	return fields[:len(fields)-n], l, err
+}
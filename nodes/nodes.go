@@ -5,10 +5,94 @@ import (
 	"strings"
 	"bufio"
 	"strconv"
+	"sort"
+	"path/filepath"
 
 	"../location"
 )
 
+/////////////
+// Program //
+/////////////
+
+// Program is the root of the ast of a source file.
+type Program struct {
+	Statements []Statement
+	location.Location
+}
+
+func (p Program) PrintTree(indent string, last bool) {
+	fmt.Print(indent)
+
+	if last {
+		fmt.Print("\\-")
+		indent += "  "
+	} else {
+		fmt.Print("|-")
+		indent += "| "
+	}
+
+	fmt.Print("Program\n")
+
+	for n, s := range p.Statements {
+		s.PrintTree(indent, n == len(p.Statements)-1)
+	}
+}
+
+func (p Program) String() string {
+	var b strings.Builder
+
+	b.WriteString(fmt.Sprintf("Program program %d %s", len(p.Statements), p.Location.Encode()))
+	for _, s := range p.Statements {
+		b.WriteString("\n"+s.String())
+	}
+
+	return b.String()
+}
+
+func (p Program) GetLocation() location.Location {
+	return p.Location
+}
+
+func ProgramFromScanner(s *bufio.Scanner) (*Program, error) {
+	vals := strings.Split(s.Text(), " ")
+
+	if vals[0] != "Program" {
+		return nil, fmt.Errorf("Failed to parse %q into Program", s.Text())
+	}
+
+	loc, err := location.LocationFromString(strings.Join(vals[3:], " "))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse Program from scanner: %s", err)
+	}
+
+	p := &Program {
+		Statements: make([]Statement, 0),
+		Location: loc,
+	}
+
+	numSubnodes, err := strconv.Atoi(vals[2])
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse Program from scanner: %s", err)
+	}
+
+	for i := 0; i < numSubnodes; i++ {
+		ok := s.Scan()
+		if !ok {
+			return nil, fmt.Errorf("Failed to parse Program from scanner: EOF")
+		}
+
+		statement, err := StatementFromScanner(s)
+		if err != nil {
+			return nil, err
+		}
+
+		p.Statements = append(p.Statements, statement)
+	}
+
+	return p, nil
+}
+
 ////////////////
 // Statements //
 ////////////////
@@ -28,7 +112,12 @@ func init() {
 	statementScannerParsers["If"] = IfFromScanner
 	statementScannerParsers["For"] = ForFromScanner
 	statementScannerParsers["Assignment"] = AssignmentFromScanner
+	statementScannerParsers["Const"] = ConstFromScanner
 	statementScannerParsers["Scope"] = ScopeFromScanner
+	statementScannerParsers["Import"] = ImportFromScanner
+	statementScannerParsers["Go"] = GoFromScanner
+	statementScannerParsers["Select"] = SelectFromScanner
+	statementScannerParsers["Test"] = TestFromScanner
 }
 
 func StatementFromScanner(s *bufio.Scanner) (Statement, error) {
@@ -78,11 +167,11 @@ func (i If) String() string {
 	b.WriteString("If if ")
 
 	if i.Alternative == nil {
-		b.WriteString("2 "+i.Location.String()+"\n")
+		b.WriteString("2 "+i.Location.Encode()+"\n")
 		b.WriteString(i.Condition.String()+"\n")
 		b.WriteString(i.Primary.String())
 	} else {
-		b.WriteString("3 "+i.Location.String()+"\n")
+		b.WriteString("3 "+i.Location.Encode()+"\n")
 		b.WriteString(i.Condition.String()+"\n")
 		b.WriteString(i.Primary.String()+"\n")
 		b.WriteString(i.Alternative.String())
@@ -180,7 +269,7 @@ func (f For) PrintTree(indent string, last bool) {
 func (f For) String() string {
 	var b strings.Builder
 
-	b.WriteString("For for 4 "+f.Location.String()+"\n")
+	b.WriteString("For for 4 "+f.Location.Encode()+"\n")
 
 	b.WriteString(f.PreStatement.String()+"\n")
 	b.WriteString(f.Condition.String()+"\n")
@@ -281,7 +370,7 @@ func (a Assignment) PrintTree(indent string, last bool) {
 func (a Assignment) String() string {
 	var b strings.Builder
 
-	b.WriteString("Assignment = 2 "+a.Location.String()+"\n")
+	b.WriteString("Assignment = 2 "+a.Location.Encode()+"\n")
 	b.WriteString(a.Place.String()+"\n")
 	b.WriteString(a.Value.String())
 
@@ -331,6 +420,70 @@ func AssignmentFromScanner(s *bufio.Scanner) (Statement, error) {
 	return a, nil
 }
 
+// Const declares a constant, its value is computed before the program runs
+// and replaces every use of Name.
+type Const struct {
+	Name string
+	Value Expression
+	location.Location
+}
+
+func (c Const) statementNode() {}
+
+func (c Const) PrintTree(indent string, last bool) {
+	fmt.Print(indent)
+
+	if last {
+		fmt.Print("\\-")
+		indent += "  "
+	} else {
+		fmt.Print("|-")
+		indent += "| "
+	}
+
+	fmt.Printf("const %s\n", c.Name)
+
+	c.Value.PrintTree(indent, true)
+}
+
+func (c Const) String() string {
+	return "Const "+c.Name+" 1 "+c.Location.Encode()+"\n"+c.Value.String()
+}
+
+func (c Const) GetLocation() location.Location {
+	return c.Location
+}
+
+func ConstFromScanner(s *bufio.Scanner) (Statement, error) {
+	vals := strings.Split(s.Text(), " ")
+
+	if vals[0] != "Const" {
+		return nil, fmt.Errorf("Failed to parse %q into Const", s.Text())
+	}
+
+	loc, err := location.LocationFromString(strings.Join(vals[3:], " "))
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Const {
+		Name: vals[1],
+		Location: loc,
+	}
+
+	ok := s.Scan()
+	if !ok {
+		return nil, fmt.Errorf("Failed to parse Const from scanner: EOF")
+	}
+
+	c.Value, err = ExpressionFromScanner(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
 type Scope struct {
 	Statements []Statement
 	location.Location
@@ -350,21 +503,18 @@ func (s Scope) PrintTree(indent string, last bool) {
 
 	fmt.Print("Scope\n")
 
-	for _, s := range s.Statements[:len(s.Statements)-1] {
-		s.PrintTree(indent, false)
+	for n, statement := range s.Statements {
+		statement.PrintTree(indent, n == len(s.Statements)-1)
 	}
-
-	s.Statements[len(s.Statements)-1].PrintTree(indent, true)
 }
 
 func (s Scope) String() string {
 	var b strings.Builder
 
-	b.WriteString(fmt.Sprintf("Scope { %d %s\n", len(s.Statements), s.Location))
-	for _, s := range s.Statements[:len(s.Statements)-1] {
-		b.WriteString(s.String()+"\n")
+	b.WriteString(fmt.Sprintf("Scope { %d %s", len(s.Statements), s.Location.Encode()))
+	for _, statement := range s.Statements {
+		b.WriteString("\n"+statement.String())
 	}
-	b.WriteString(s.Statements[len(s.Statements)-1].String())
 
 	return b.String()
 }
@@ -413,115 +563,85 @@ func ScopeFromScanner(s *bufio.Scanner) (Statement, error) {
 }
 
 
-type ExpressionStatement struct {
-	Expression
-}
-
-func (e ExpressionStatement) statementNode() {}
-
-func (e ExpressionStatement) PrintTree(indent string, last bool) {
-	e.Expression.PrintTree(indent, last)
-}
-
-func (e ExpressionStatement) String() string {
-	return e.Expression.String()
-}
-
-func (e ExpressionStatement) GetLocation() location.Location {
-	return e.Expression.GetLocation()
-}
-
-func ExpressionStatementFromScanner(s *bufio.Scanner) (Statement, error) {
-	e, err := ExpressionFromScanner(s)
-	if err != nil {
-		return nil, err
-	}
-
-	return &ExpressionStatement{e}, nil
-}
-
-/////////////////
-// Expressions //
-/////////////////
-
-type Expression interface {
-	expressionNode()
-	PrintTree(indent string, last bool)
-	String() string
-	GetLocation() location.Location
-}
-
-var expressionScannerParsers map[string] func(s *bufio.Scanner) (Expression, error)
-
-func init() {
-	expressionScannerParsers = make(map[string]func(s *bufio.Scanner)(Expression, error))
-
-	expressionScannerParsers["IntLiteral"] = IntLiteralFromScanner
-	expressionScannerParsers["FloatLiteral"] = FloatLiteralFromScanner
-	expressionScannerParsers["StringLiteral"] = StringLiteralFromScanner
-	expressionScannerParsers["BoolLiteral"] = BoolLiteralFromScanner
-	expressionScannerParsers["Identifier"] = IdentifierFromScanner
-	expressionScannerParsers["Call"] = CallFromScanner
-	expressionScannerParsers["Index"] = IndexFromScanner
-	expressionScannerParsers["Operator"] = OperatorFromScanner
-	expressionScannerParsers["UnaryOperator"] = UnaryOperatorFromScanner
+// Import makes the top level variables of another source file available
+// under a namespace. The parser only fills in Path, the linker resolves it
+// and adds the parsed file as Body.
+type Import struct {
+	Path string
+	Body *Program
+	location.Location
 }
 
-func ExpressionFromScanner(s *bufio.Scanner) (Expression, error) {
-	vals := strings.Split(s.Text(), " ")
-
-	parser, exists := expressionScannerParsers[vals[0]]
-	if exists {
-		return parser(s)
-	} else {
-		return nil, fmt.Errorf("Failed to parse %q into Expression", s.Text())
-	}
-}
+func (i Import) statementNode() {}
 
-type IntLiteral struct {
-	Value int
-	location.Location
+// Namespace returns the name the imported file's variables are accessed
+// through, the file name without directories or extension.
+func (i Import) Namespace() string {
+	return strings.TrimSuffix(filepath.Base(i.Path), filepath.Ext(i.Path))
 }
 
-func (i IntLiteral) expressionNode() {}
-
-func (i IntLiteral) PrintTree(indent string, last bool) {
+func (i Import) PrintTree(indent string, last bool) {
 	fmt.Print(indent)
 
 	if last {
 		fmt.Print("\\-")
+		indent += "  "
 	} else {
 		fmt.Print("|-")
+		indent += "| "
 	}
 
-	fmt.Printf("%d\n", i.Value)
+	fmt.Printf("Import %q\n", i.Path)
+
+	if i.Body != nil {
+		i.Body.PrintTree(indent, true)
+	}
 }
 
-func (i IntLiteral) String() string {
-	return "IntLiteral "+fmt.Sprintf("%d", i.Value)+" 0 "+i.Location.String()
+func (i Import) String() string {
+	if i.Body == nil {
+		return "Import "+strconv.Quote(i.Path)+" 0 "+i.Location.Encode()
+	}
+
+	return "Import "+strconv.Quote(i.Path)+" 1 "+i.Location.Encode()+"\n"+i.Body.String()
 }
 
-func (i IntLiteral) GetLocation() location.Location {
+func (i Import) GetLocation() location.Location {
 	return i.Location
 }
 
-func IntLiteralFromScanner(s *bufio.Scanner) (Expression, error) {
+func ImportFromScanner(s *bufio.Scanner) (Statement, error) {
 	vals := strings.Split(s.Text(), " ")
 
-	if vals[0] != "IntLiteral" {
-		return nil, fmt.Errorf("Failed to parse %q into IntLiteral", s.Text())
+	if vals[0] != "Import" {
+		return nil, fmt.Errorf("Failed to parse %q into Import", s.Text())
 	}
 
-	loc, err := location.LocationFromString(strings.Join(vals[3:], " "))
+	fields, loc, err := location.SplitFields(vals)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to parse IntLiteral from scanner: %s", err)
+		return nil, fmt.Errorf("Failed to parse Import from scanner: %s", err)
 	}
 
-	i := &IntLiteral {
+	path, err := strconv.Unquote(strings.Join(fields[1:len(fields)-1], " "))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse Import from scanner: %s", err)
+	}
+
+	i := &Import {
+		Path: path,
 		Location: loc,
 	}
 
-	i.Value, err = strconv.Atoi(vals[1])
+	if fields[len(fields)-1] == "0" {
+		return i, nil
+	}
+
+	ok := s.Scan()
+	if !ok {
+		return nil, fmt.Errorf("Failed to parse Import from scanner: EOF")
+	}
+
+	i.Body, err = ProgramFromScanner(s)
 	if err != nil {
 		return nil, err
 	}
@@ -529,100 +649,560 @@ func IntLiteralFromScanner(s *bufio.Scanner) (Expression, error) {
 	return i, nil
 }
 
-type FloatLiteral struct {
-	Value float32
+// Go runs a call in a new task, concurrently with the rest of the program.
+type Go struct {
+	Call *Call
 	location.Location
 }
 
-func (f FloatLiteral) expressionNode() {}
+func (g Go) statementNode() {}
 
-func (f FloatLiteral) PrintTree(indent string, last bool) {
+func (g Go) PrintTree(indent string, last bool) {
 	fmt.Print(indent)
 
 	if last {
 		fmt.Print("\\-")
+		indent += "  "
 	} else {
 		fmt.Print("|-")
+		indent += "| "
 	}
 
-	fmt.Printf("%g\n", f.Value)
+	fmt.Print("Go\n")
+
+	g.Call.PrintTree(indent, true)
 }
 
-func (f FloatLiteral) String() string {
-	return "FloatLiteral "+fmt.Sprintf("%g", f.Value)+" 0 "+f.Location.String()
+func (g Go) String() string {
+	return "Go go 1 "+g.Location.Encode()+"\n"+g.Call.String()
 }
 
-func (f FloatLiteral) GetLocation() location.Location {
-	return f.Location
+func (g Go) GetLocation() location.Location {
+	return g.Location
 }
 
-func FloatLiteralFromScanner(s *bufio.Scanner) (Expression, error) {
+func GoFromScanner(s *bufio.Scanner) (Statement, error) {
 	vals := strings.Split(s.Text(), " ")
 
-	if vals[0] != "FloatLiteral" {
-		return nil, fmt.Errorf("Failed to parse %q into FloatLiteral", s.Text())
+	if vals[0] != "Go" {
+		return nil, fmt.Errorf("Failed to parse %q into Go", s.Text())
 	}
 
 	loc, err := location.LocationFromString(strings.Join(vals[3:], " "))
 	if err != nil {
-		return nil, fmt.Errorf("Failed to parse FloatLiteral from scanner: %s", err)
+		return nil, err
 	}
 
-	f := &FloatLiteral {
-		Location: loc,
+	ok := s.Scan()
+	if !ok {
+		return nil, fmt.Errorf("Failed to parse Go from scanner: EOF")
 	}
 
-	val, err := strconv.ParseFloat(vals[1], 32)
+	e, err := ExpressionFromScanner(s)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to parse FloatLiteral from scanner: %s", err)
+		return nil, err
+	}
+
+	call, ok := e.(*Call)
+	if !ok {
+		return nil, fmt.Errorf("Failed to parse Go from scanner: %q is not a Call", s.Text())
 	}
 
-	f.Value = float32(val)
+	return &Go {
+		Call: call,
+		Location: loc,
+	}, nil
+}
 
-	return f, nil
+// Select waits until the channel operation of one of its cases can proceed
+// and runs that case, or runs Default if none can and it isn't nil.
+type Select struct {
+	Cases []*SelectCase
+	Default Statement
+	location.Location
 }
 
-type StringLiteral struct {
-	Value string
+// SelectCase is a send or receive Call, optionally assigning the received
+// value to Place, and the Body run when it is selected.
+type SelectCase struct {
+	Place Expression
+	Call *Call
+	Body Statement
 	location.Location
 }
 
-func (s StringLiteral) expressionNode() {}
+func (s Select) statementNode() {}
 
-func (s StringLiteral) PrintTree(indent string, last bool) {
+func (s Select) PrintTree(indent string, last bool) {
 	fmt.Print(indent)
 
 	if last {
 		fmt.Print("\\-")
+		indent += "  "
 	} else {
 		fmt.Print("|-")
+		indent += "| "
 	}
 
-	fmt.Printf("%q\n", s.Value)
-}
-
-func (s StringLiteral) String() string {
-	return "StringLiteral "+s.Value+" 0 "+s.Location.String()
-}
-
-func (s StringLiteral) GetLocation() location.Location {
-	return s.Location
-}
-
-func StringLiteralFromScanner(s *bufio.Scanner) (Expression, error) {
-	vals := strings.Split(s.Text(), " ")
+	fmt.Print("Select\n")
 
-	if vals[0] != "StringLiteral" {
-		return nil, fmt.Errorf("Failed to parse %q into StringLiteral", s.Text())
+	for n, c := range s.Cases {
+		c.PrintTree(indent, n == len(s.Cases)-1 && s.Default == nil)
 	}
 
-	loc, err := location.LocationFromString(strings.Join(vals[len(vals)-3:], " "))
-	if err != nil {
-		return nil, fmt.Errorf("Failed to parse StringLiteral from scanner: %s", err)
+	if s.Default != nil {
+		s.Default.PrintTree(indent, true)
 	}
+}
 
-	return &StringLiteral {
-		Value: strings.Join(vals[1:len(vals)-4], " "),
+func (c SelectCase) PrintTree(indent string, last bool) {
+	fmt.Print(indent)
+
+	if last {
+		fmt.Print("\\-")
+		indent += "  "
+	} else {
+		fmt.Print("|-")
+		indent += "| "
+	}
+
+	fmt.Print("Case\n")
+
+	if c.Place != nil {
+		c.Place.PrintTree(indent, false)
+	}
+	c.Call.PrintTree(indent, false)
+	c.Body.PrintTree(indent, true)
+}
+
+func (s Select) String() string {
+	var b strings.Builder
+
+	numSubnodes := len(s.Cases)
+	if s.Default != nil {
+		numSubnodes++
+	}
+
+	b.WriteString(fmt.Sprintf("Select select %d %s", numSubnodes, s.Location.Encode()))
+	for _, c := range s.Cases {
+		b.WriteString("\n"+c.String())
+	}
+	if s.Default != nil {
+		b.WriteString("\nDefault default 1 "+s.Default.GetLocation().Encode()+"\n"+s.Default.String())
+	}
+
+	return b.String()
+}
+
+func (c SelectCase) String() string {
+	if c.Place == nil {
+		return "Case case 2 "+c.Location.Encode()+"\n"+c.Call.String()+"\n"+c.Body.String()
+	}
+
+	return "Case case 3 "+c.Location.Encode()+"\n"+c.Place.String()+"\n"+c.Call.String()+"\n"+c.Body.String()
+}
+
+func (s Select) GetLocation() location.Location {
+	return s.Location
+}
+
+func SelectFromScanner(s *bufio.Scanner) (Statement, error) {
+	vals := strings.Split(s.Text(), " ")
+
+	if vals[0] != "Select" {
+		return nil, fmt.Errorf("Failed to parse %q into Select", s.Text())
+	}
+
+	loc, err := location.LocationFromString(strings.Join(vals[3:], " "))
+	if err != nil {
+		return nil, err
+	}
+
+	selectNode := &Select {
+		Cases: make([]*SelectCase, 0),
+		Location: loc,
+	}
+
+	numSubnodes, err := strconv.Atoi(vals[2])
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse Select from scanner: %s", err)
+	}
+
+	for i := 0; i < numSubnodes; i++ {
+		ok := s.Scan()
+		if !ok {
+			return nil, fmt.Errorf("Failed to parse Select from scanner: EOF")
+		}
+
+		if strings.HasPrefix(s.Text(), "Default ") {
+			ok := s.Scan()
+			if !ok {
+				return nil, fmt.Errorf("Failed to parse Select from scanner: EOF")
+			}
+
+			selectNode.Default, err = StatementFromScanner(s)
+			if err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		c, err := selectCaseFromScanner(s)
+		if err != nil {
+			return nil, err
+		}
+
+		selectNode.Cases = append(selectNode.Cases, c)
+	}
+
+	return selectNode, nil
+}
+
+func selectCaseFromScanner(s *bufio.Scanner) (*SelectCase, error) {
+	vals := strings.Split(s.Text(), " ")
+
+	if vals[0] != "Case" {
+		return nil, fmt.Errorf("Failed to parse %q into SelectCase", s.Text())
+	}
+
+	loc, err := location.LocationFromString(strings.Join(vals[3:], " "))
+	if err != nil {
+		return nil, err
+	}
+
+	c := &SelectCase {
+		Location: loc,
+	}
+
+	ok := s.Scan()
+	if !ok {
+		return nil, fmt.Errorf("Failed to parse SelectCase from scanner: EOF")
+	}
+
+	e, err := ExpressionFromScanner(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if vals[2] == "3" {
+		c.Place = e
+
+		ok = s.Scan()
+		if !ok {
+			return nil, fmt.Errorf("Failed to parse SelectCase from scanner: EOF")
+		}
+
+		e, err = ExpressionFromScanner(s)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	c.Call, ok = e.(*Call)
+	if !ok {
+		return nil, fmt.Errorf("Failed to parse SelectCase from scanner: %q is not a Call", s.Text())
+	}
+
+	ok = s.Scan()
+	if !ok {
+		return nil, fmt.Errorf("Failed to parse SelectCase from scanner: EOF")
+	}
+
+	c.Body, err = StatementFromScanner(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Test is a named block of statements only run by the interpreter's test
+// mode, which runs each test of a file on its own.
+type Test struct {
+	Name string
+	Body Statement
+	location.Location
+}
+
+func (t Test) statementNode() {}
+
+func (t Test) PrintTree(indent string, last bool) {
+	fmt.Print(indent)
+
+	if last {
+		fmt.Print("\\-")
+		indent += "  "
+	} else {
+		fmt.Print("|-")
+		indent += "| "
+	}
+
+	fmt.Printf("Test %q\n", t.Name)
+
+	t.Body.PrintTree(indent, true)
+}
+
+func (t Test) String() string {
+	return "Test "+strconv.Quote(t.Name)+" 1 "+t.Location.Encode()+"\n"+t.Body.String()
+}
+
+func (t Test) GetLocation() location.Location {
+	return t.Location
+}
+
+func TestFromScanner(s *bufio.Scanner) (Statement, error) {
+	vals := strings.Split(s.Text(), " ")
+
+	if vals[0] != "Test" {
+		return nil, fmt.Errorf("Failed to parse %q into Test", s.Text())
+	}
+
+	fields, loc, err := location.SplitFields(vals)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse Test from scanner: %s", err)
+	}
+
+	name, err := strconv.Unquote(strings.Join(fields[1:len(fields)-1], " "))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse Test from scanner: %s", err)
+	}
+
+	t := &Test {
+		Name: name,
+		Location: loc,
+	}
+
+	ok := s.Scan()
+	if !ok {
+		return nil, fmt.Errorf("Failed to parse Test from scanner: EOF")
+	}
+
+	t.Body, err = StatementFromScanner(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+type ExpressionStatement struct {
+	Expression
+}
+
+func (e ExpressionStatement) statementNode() {}
+
+func (e ExpressionStatement) PrintTree(indent string, last bool) {
+	e.Expression.PrintTree(indent, last)
+}
+
+func (e ExpressionStatement) String() string {
+	return e.Expression.String()
+}
+
+func (e ExpressionStatement) GetLocation() location.Location {
+	return e.Expression.GetLocation()
+}
+
+func ExpressionStatementFromScanner(s *bufio.Scanner) (Statement, error) {
+	e, err := ExpressionFromScanner(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ExpressionStatement{e}, nil
+}
+
+/////////////////
+// Expressions //
+/////////////////
+
+type Expression interface {
+	expressionNode()
+	PrintTree(indent string, last bool)
+	String() string
+	GetLocation() location.Location
+}
+
+var expressionScannerParsers map[string] func(s *bufio.Scanner) (Expression, error)
+
+func init() {
+	expressionScannerParsers = make(map[string]func(s *bufio.Scanner)(Expression, error))
+
+	expressionScannerParsers["IntLiteral"] = IntLiteralFromScanner
+	expressionScannerParsers["FloatLiteral"] = FloatLiteralFromScanner
+	expressionScannerParsers["StringLiteral"] = StringLiteralFromScanner
+	expressionScannerParsers["BoolLiteral"] = BoolLiteralFromScanner
+	expressionScannerParsers["Interpolation"] = InterpolationFromScanner
+	expressionScannerParsers["ArrayLiteral"] = ArrayLiteralFromScanner
+	expressionScannerParsers["MapLiteral"] = MapLiteralFromScanner
+	expressionScannerParsers["Member"] = MemberFromScanner
+	expressionScannerParsers["Identifier"] = IdentifierFromScanner
+	expressionScannerParsers["Call"] = CallFromScanner
+	expressionScannerParsers["Index"] = IndexFromScanner
+	expressionScannerParsers["Operator"] = OperatorFromScanner
+	expressionScannerParsers["UnaryOperator"] = UnaryOperatorFromScanner
+}
+
+func ExpressionFromScanner(s *bufio.Scanner) (Expression, error) {
+	vals := strings.Split(s.Text(), " ")
+
+	parser, exists := expressionScannerParsers[vals[0]]
+	if exists {
+		return parser(s)
+	} else {
+		return nil, fmt.Errorf("Failed to parse %q into Expression", s.Text())
+	}
+}
+
+type IntLiteral struct {
+	Value int
+	location.Location
+}
+
+func (i IntLiteral) expressionNode() {}
+
+func (i IntLiteral) PrintTree(indent string, last bool) {
+	fmt.Print(indent)
+
+	if last {
+		fmt.Print("\\-")
+	} else {
+		fmt.Print("|-")
+	}
+
+	fmt.Printf("%d\n", i.Value)
+}
+
+func (i IntLiteral) String() string {
+	return "IntLiteral "+fmt.Sprintf("%d", i.Value)+" 0 "+i.Location.Encode()
+}
+
+func (i IntLiteral) GetLocation() location.Location {
+	return i.Location
+}
+
+func IntLiteralFromScanner(s *bufio.Scanner) (Expression, error) {
+	vals := strings.Split(s.Text(), " ")
+
+	if vals[0] != "IntLiteral" {
+		return nil, fmt.Errorf("Failed to parse %q into IntLiteral", s.Text())
+	}
+
+	loc, err := location.LocationFromString(strings.Join(vals[3:], " "))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse IntLiteral from scanner: %s", err)
+	}
+
+	i := &IntLiteral {
+		Location: loc,
+	}
+
+	i.Value, err = strconv.Atoi(vals[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return i, nil
+}
+
+type FloatLiteral struct {
+	Value float64
+	location.Location
+}
+
+func (f FloatLiteral) expressionNode() {}
+
+func (f FloatLiteral) PrintTree(indent string, last bool) {
+	fmt.Print(indent)
+
+	if last {
+		fmt.Print("\\-")
+	} else {
+		fmt.Print("|-")
+	}
+
+	fmt.Printf("%g\n", f.Value)
+}
+
+func (f FloatLiteral) String() string {
+	return "FloatLiteral "+fmt.Sprintf("%g", f.Value)+" 0 "+f.Location.Encode()
+}
+
+func (f FloatLiteral) GetLocation() location.Location {
+	return f.Location
+}
+
+func FloatLiteralFromScanner(s *bufio.Scanner) (Expression, error) {
+	vals := strings.Split(s.Text(), " ")
+
+	if vals[0] != "FloatLiteral" {
+		return nil, fmt.Errorf("Failed to parse %q into FloatLiteral", s.Text())
+	}
+
+	loc, err := location.LocationFromString(strings.Join(vals[3:], " "))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse FloatLiteral from scanner: %s", err)
+	}
+
+	f := &FloatLiteral {
+		Location: loc,
+	}
+
+	f.Value, err = strconv.ParseFloat(vals[1], 64)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse FloatLiteral from scanner: %s", err)
+	}
+
+	return f, nil
+}
+
+type StringLiteral struct {
+	Value string
+	location.Location
+}
+
+func (s StringLiteral) expressionNode() {}
+
+func (s StringLiteral) PrintTree(indent string, last bool) {
+	fmt.Print(indent)
+
+	if last {
+		fmt.Print("\\-")
+	} else {
+		fmt.Print("|-")
+	}
+
+	fmt.Printf("%q\n", s.Value)
+}
+
+func (s StringLiteral) String() string {
+	return "StringLiteral "+strconv.Quote(s.Value)+" 0 "+s.Location.Encode()
+}
+
+func (s StringLiteral) GetLocation() location.Location {
+	return s.Location
+}
+
+func StringLiteralFromScanner(s *bufio.Scanner) (Expression, error) {
+	vals := strings.Split(s.Text(), " ")
+
+	if vals[0] != "StringLiteral" {
+		return nil, fmt.Errorf("Failed to parse %q into StringLiteral", s.Text())
+	}
+
+	fields, loc, err := location.SplitFields(vals)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse StringLiteral from scanner: %s", err)
+	}
+
+	value, err := strconv.Unquote(strings.Join(fields[1:len(fields)-1], " "))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse StringLiteral from scanner: %s", err)
+	}
+
+	return &StringLiteral {
+		Value: value,
 		Location: loc,
 	}, nil
 }
@@ -647,7 +1227,7 @@ func (b BoolLiteral) PrintTree(indent string, last bool) {
 }
 
 func (b BoolLiteral) String() string {
-	return "BoolLiteral "+fmt.Sprintf("%t", b.Value)+" 0 "+b.Location.String()
+	return "BoolLiteral "+fmt.Sprintf("%t", b.Value)+" 0 "+b.Location.Encode()
 }
 
 func (b BoolLiteral) GetLocation() location.Location {
@@ -682,6 +1262,336 @@ func BoolLiteralFromScanner(s *bufio.Scanner) (Expression, error) {
 	}
 }
 
+type Interpolation struct {
+	Parts []Expression
+	location.Location
+}
+
+func (i Interpolation) expressionNode() {}
+
+func (i Interpolation) PrintTree(indent string, last bool) {
+	fmt.Print(indent)
+
+	if last {
+		fmt.Print("\\-")
+		indent += "  "
+	} else {
+		fmt.Print("|-")
+		indent += "| "
+	}
+
+	fmt.Print("Interpolation\n")
+
+	for n, p := range i.Parts {
+		p.PrintTree(indent, n == len(i.Parts)-1)
+	}
+}
+
+func (i Interpolation) String() string {
+	var b strings.Builder
+
+	b.WriteString(fmt.Sprintf("Interpolation ${ %d %s", len(i.Parts), i.Location.Encode()))
+	for _, p := range i.Parts {
+		b.WriteString("\n"+p.String())
+	}
+
+	return b.String()
+}
+
+func (i Interpolation) GetLocation() location.Location {
+	return i.Location
+}
+
+func InterpolationFromScanner(s *bufio.Scanner) (Expression, error) {
+	vals := strings.Split(s.Text(), " ")
+
+	if vals[0] != "Interpolation" {
+		return nil, fmt.Errorf("Failed to parse %q into Interpolation", s.Text())
+	}
+
+	loc, err := location.LocationFromString(strings.Join(vals[3:], " "))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse Interpolation from scanner: %s", err)
+	}
+
+	i := &Interpolation {
+		Parts: make([]Expression, 0),
+		Location: loc,
+	}
+
+	numSubnodes, err := strconv.Atoi(vals[2])
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse Interpolation from scanner: %s", err)
+	}
+
+	for n := 0; n < numSubnodes; n++ {
+		ok := s.Scan()
+		if !ok {
+			return nil, fmt.Errorf("Failed to parse Interpolation from scanner: EOF")
+		}
+
+		part, err := ExpressionFromScanner(s)
+		if err != nil {
+			return nil, err
+		}
+
+		i.Parts = append(i.Parts, part)
+	}
+
+	return i, nil
+}
+
+type ArrayLiteral struct {
+	Elements []Expression
+	location.Location
+}
+
+func (a ArrayLiteral) expressionNode() {}
+
+func (a ArrayLiteral) PrintTree(indent string, last bool) {
+	fmt.Print(indent)
+
+	if last {
+		fmt.Print("\\-")
+		indent += "  "
+	} else {
+		fmt.Print("|-")
+		indent += "| "
+	}
+
+	fmt.Print("Array\n")
+
+	for n, e := range a.Elements {
+		e.PrintTree(indent, n == len(a.Elements)-1)
+	}
+}
+
+func (a ArrayLiteral) String() string {
+	var b strings.Builder
+
+	b.WriteString(fmt.Sprintf("ArrayLiteral [ %d %s", len(a.Elements), a.Location.Encode()))
+	for _, e := range a.Elements {
+		b.WriteString("\n"+e.String())
+	}
+
+	return b.String()
+}
+
+func (a ArrayLiteral) GetLocation() location.Location {
+	return a.Location
+}
+
+func ArrayLiteralFromScanner(s *bufio.Scanner) (Expression, error) {
+	vals := strings.Split(s.Text(), " ")
+
+	if vals[0] != "ArrayLiteral" {
+		return nil, fmt.Errorf("Failed to parse %q into ArrayLiteral", s.Text())
+	}
+
+	loc, err := location.LocationFromString(strings.Join(vals[3:], " "))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse ArrayLiteral from scanner: %s", err)
+	}
+
+	a := &ArrayLiteral {
+		Elements: make([]Expression, 0),
+		Location: loc,
+	}
+
+	numSubnodes, err := strconv.Atoi(vals[2])
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse ArrayLiteral from scanner: %s", err)
+	}
+
+	for n := 0; n < numSubnodes; n++ {
+		ok := s.Scan()
+		if !ok {
+			return nil, fmt.Errorf("Failed to parse ArrayLiteral from scanner: EOF")
+		}
+
+		element, err := ExpressionFromScanner(s)
+		if err != nil {
+			return nil, err
+		}
+
+		a.Elements = append(a.Elements, element)
+	}
+
+	return a, nil
+}
+
+// MapLiteral maps string keys to values. It has no syntax, maps are only
+// created while the program runs, e.g. by jsonParse.
+type MapLiteral struct {
+	Values map[string] Expression
+	location.Location
+}
+
+func (m MapLiteral) expressionNode() {}
+
+// Keys returns the keys of m in sorted order.
+func (m MapLiteral) Keys() []string {
+	keys := make([]string, 0, len(m.Values))
+	for k := range m.Values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func (m MapLiteral) PrintTree(indent string, last bool) {
+	fmt.Print(indent)
+
+	if last {
+		fmt.Print("\\-")
+		indent += "  "
+	} else {
+		fmt.Print("|-")
+		indent += "| "
+	}
+
+	fmt.Print("Map\n")
+
+	keys := m.Keys()
+	for n, k := range keys {
+		StringLiteral{Value: k, Location: m.Location}.PrintTree(indent, false)
+		m.Values[k].PrintTree(indent, n == len(keys)-1)
+	}
+}
+
+func (m MapLiteral) String() string {
+	var b strings.Builder
+
+	b.WriteString(fmt.Sprintf("MapLiteral { %d %s", 2*len(m.Values), m.Location.Encode()))
+	for _, k := range m.Keys() {
+		b.WriteString("\n"+StringLiteral{Value: k, Location: m.Location}.String())
+		b.WriteString("\n"+m.Values[k].String())
+	}
+
+	return b.String()
+}
+
+func (m MapLiteral) GetLocation() location.Location {
+	return m.Location
+}
+
+func MapLiteralFromScanner(s *bufio.Scanner) (Expression, error) {
+	vals := strings.Split(s.Text(), " ")
+
+	if vals[0] != "MapLiteral" {
+		return nil, fmt.Errorf("Failed to parse %q into MapLiteral", s.Text())
+	}
+
+	loc, err := location.LocationFromString(strings.Join(vals[3:], " "))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse MapLiteral from scanner: %s", err)
+	}
+
+	m := &MapLiteral {
+		Values: make(map[string] Expression),
+		Location: loc,
+	}
+
+	numSubnodes, err := strconv.Atoi(vals[2])
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse MapLiteral from scanner: %s", err)
+	}
+
+	// Subnodes alternate between a key and its value
+	for n := 0; n < numSubnodes/2; n++ {
+		ok := s.Scan()
+		if !ok {
+			return nil, fmt.Errorf("Failed to parse MapLiteral from scanner: EOF")
+		}
+
+		key, err := ExpressionFromScanner(s)
+		if err != nil {
+			return nil, err
+		}
+
+		k, isString := key.(*StringLiteral)
+		if !isString {
+			return nil, fmt.Errorf("Failed to parse MapLiteral from scanner: key %q is not a StringLiteral", s.Text())
+		}
+
+		ok = s.Scan()
+		if !ok {
+			return nil, fmt.Errorf("Failed to parse MapLiteral from scanner: EOF")
+		}
+
+		m.Values[k.Value], err = ExpressionFromScanner(s)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// Member accesses the variable Name in the namespace of an import.
+type Member struct {
+	Structure Expression
+	Name string
+	location.Location
+}
+
+func (m Member) expressionNode() {}
+
+func (m Member) PrintTree(indent string, last bool) {
+	fmt.Print(indent)
+
+	if last {
+		fmt.Print("\\-")
+		indent += "  "
+	} else {
+		fmt.Print("|-")
+		indent += "| "
+	}
+
+	fmt.Printf(".%s\n", m.Name)
+
+	m.Structure.PrintTree(indent, true)
+}
+
+func (m Member) String() string {
+	return "Member "+m.Name+" 1 "+m.Location.Encode()+"\n"+m.Structure.String()
+}
+
+func (m Member) GetLocation() location.Location {
+	return m.Location
+}
+
+func MemberFromScanner(s *bufio.Scanner) (Expression, error) {
+	vals := strings.Split(s.Text(), " ")
+
+	if vals[0] != "Member" {
+		return nil, fmt.Errorf("Failed to parse %q into Member", s.Text())
+	}
+
+	loc, err := location.LocationFromString(strings.Join(vals[3:], " "))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse Member from scanner: %s", err)
+	}
+
+	m := &Member {
+		Name: vals[1],
+		Location: loc,
+	}
+
+	ok := s.Scan()
+	if !ok {
+		return nil, fmt.Errorf("Failed to parse Member from scanner: EOF")
+	}
+
+	m.Structure, err = ExpressionFromScanner(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
 type Identifier struct {
 	Name string
 	location.Location
@@ -702,7 +1612,7 @@ func (i Identifier) PrintTree(indent string, last bool) {
 }
 
 func (i Identifier) String() string {
-	return "Identifier "+i.Name+" 0 "+i.Location.String()
+	return "Identifier "+i.Name+" 0 "+i.Location.Encode()
 }
 
 func (i Identifier) GetLocation() location.Location {
@@ -748,24 +1658,21 @@ func (c Call) PrintTree(indent string, last bool) {
 
 	fmt.Print("Call\n")
 
-	c.Function.PrintTree(indent, false)
+	c.Function.PrintTree(indent, len(c.Arguments) == 0)
 
-	for _, a := range c.Arguments[:len(c.Arguments)-1] {
-		a.PrintTree(indent, false)
+	for n, a := range c.Arguments {
+		a.PrintTree(indent, n == len(c.Arguments)-1)
 	}
-
-	c.Arguments[len(c.Arguments)-1].PrintTree(indent, true)
 }
 
 func (c Call) String() string {
 	var b strings.Builder
 
-	b.WriteString(fmt.Sprintf("Call ( %d %s\n", 1+len(c.Arguments), c.Location))
-	b.WriteString(c.Function.String()+"\n")
-	for _, a := range c.Arguments[:len(c.Arguments)-1] {
-		b.WriteString(a.String()+"\n")
+	b.WriteString(fmt.Sprintf("Call ( %d %s\n", 1+len(c.Arguments), c.Location.Encode()))
+	b.WriteString(c.Function.String())
+	for _, a := range c.Arguments {
+		b.WriteString("\n"+a.String())
 	}
-	b.WriteString(c.Arguments[len(c.Arguments)-1].String())
 
 	return b.String()
 }
@@ -781,7 +1688,7 @@ func CallFromScanner(s *bufio.Scanner) (Expression, error) {
 		return nil, fmt.Errorf("Failed to parse %q into Call", s.Text())
 	}
 
-	loc, err := location.LocationFromString(strings.Join(vals[len(vals)-3:], " "))
+	loc, err := location.LocationFromString(strings.Join(vals[3:], " "))
 	if err != nil {
 		return nil, fmt.Errorf("Failed to parse Call from scanner: %s", err)
 	}
@@ -852,7 +1759,7 @@ func (i Index) PrintTree(indent string, last bool) {
 func (i Index) String() string {
 	var b strings.Builder
 
-	b.WriteString("Index [ 2 "+i.Location.String()+"\n")
+	b.WriteString("Index [ 2 "+i.Location.Encode()+"\n")
 	b.WriteString(i.Structure.String()+"\n")
 	b.WriteString(i.Index.String())
 
@@ -932,7 +1839,7 @@ func (o Operator) PrintTree(indent string, last bool) {
 func (o Operator) String() string {
 	var b strings.Builder
 
-	b.WriteString("Operator "+o.Type+" 2 "+o.Location.String()+"\n")
+	b.WriteString("Operator "+o.Type+" 2 "+o.Location.Encode()+"\n")
 	b.WriteString(o.Left.String()+"\n")
 	b.WriteString(o.Right.String())
 
@@ -1010,7 +1917,7 @@ func (u UnaryOperator) PrintTree(indent string, last bool) {
 func (u UnaryOperator) String() string {
 	var b strings.Builder
 
-	b.WriteString("UnaryOperator "+u.Type+" 1 "+u.Location.String()+"\n")
+	b.WriteString("UnaryOperator "+u.Type+" 1 "+u.Location.Encode()+"\n")
 	b.WriteString(u.Operand.String())
 
 	return b.String()
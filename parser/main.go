@@ -10,6 +10,7 @@ import (
 
 func main() {
 	tokens := make([]t.Token, 0)
+	errors := 0
 
 	if len(os.Args) > 1 {
 
@@ -21,11 +22,27 @@ func main() {
 			if err != nil {
 				panic(err)
 			}
-			tokens = append(tokens, token)
+
+			// Doc comments are for other tools, the language ignores them,
+			// and the EOF ending a lossless token stream only carries trivia
+			if token.Type == t.Error {
+				errors++
+			} else if token.Type != t.DocComment && token.Type != t.EOF {
+				tokens = append(tokens, token)
+			}
 		}
 	}
 
+	// The lexer has already reported them, parsing would only add more
+	if errors == 1 {
+		fmt.Fprintln(os.Stderr, "Not parsing input with 1 lexer error")
+		os.Exit(1)
+	} else if errors > 1 {
+		fmt.Fprintf(os.Stderr, "Not parsing input with %d lexer errors\n", errors)
+		os.Exit(1)
+	}
+
 	p := newParser(tokens)
 
-	fmt.Println(p.parseStatement().String())
+	fmt.Println(p.parseProgram().String())
 }
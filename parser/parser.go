@@ -1,13 +1,11 @@
 package main
 
 import (
-	"strings"
 	"fmt"
-	"strconv"
-	"math"
 
 	"../tokens"
 	"../nodes"
+	"../location"
 )
 
 ////////////////
@@ -38,6 +36,7 @@ var tokenTypeToPrecedence map[tokens.TokenType] Precedence = map[tokens.TokenTyp
 	tokens.Decrement: UNARY,
 	tokens.OpenBracket: CALL,
 	tokens.OpenSquareBracket: CALL,
+	tokens.Dot: CALL,
 }
 
 func getPrecedence(t tokens.TokenType) Precedence {
@@ -73,12 +72,14 @@ func newParser(t []tokens.Token) *parser {
 	p.prefixParsers[tokens.IntLiteral] = p.parseIntLiteral
 	p.prefixParsers[tokens.FloatLiteral] = p.parseFloatLiteral
 	p.prefixParsers[tokens.StringLiteral] = p.parseStringLiteral
+	p.prefixParsers[tokens.InterpolationStart] = p.parseInterpolation
 	p.prefixParsers[tokens.BoolLiteral] = p.parserBoolLiteral
 	p.prefixParsers[tokens.Identifier] = p.parseIdentifier
 	p.prefixParsers[tokens.Increment] = p.parsePrefixOperator
 	p.prefixParsers[tokens.Decrement] = p.parsePrefixOperator
 	p.prefixParsers[tokens.Subtract] = p.parsePrefixOperator
 	p.prefixParsers[tokens.OpenBracket] = p.parseSubExpression
+	p.prefixParsers[tokens.OpenSquareBracket] = p.parseArrayLiteral
 
 	p.infixParsers[tokens.LessThan] = p.parseOperator
 	p.infixParsers[tokens.GreaterThan] = p.parseOperator
@@ -89,11 +90,17 @@ func newParser(t []tokens.Token) *parser {
 	p.infixParsers[tokens.Divide] = p.parseOperator
 	p.infixParsers[tokens.OpenBracket] = p.parseCall
 	p.infixParsers[tokens.OpenSquareBracket] = p.parseIndex
+	p.infixParsers[tokens.Dot] = p.parseMember
 
 	p.statementParsers[tokens.If] = p.parseIf
 	p.statementParsers[tokens.For] = p.parseFor
 	p.statementParsers[tokens.Var] = p.parseAssignment
+	p.statementParsers[tokens.Const] = p.parseConst
 	p.statementParsers[tokens.OpenCurlyBracket] = p.parseScope
+	p.statementParsers[tokens.Import] = p.parseImport
+	p.statementParsers[tokens.Go] = p.parseGo
+	p.statementParsers[tokens.Select] = p.parseSelect
+	p.statementParsers[tokens.Test] = p.parseTest
 
 	return p
 }
@@ -133,6 +140,12 @@ func (p *parser) expect(t tokens.TokenType) {
 func (p *parser) currentToken() tokens.Token {
 	if p.tokenPosition < len(p.tokens) {
 		return p.tokens[p.tokenPosition]
+	} else if len(p.tokens) > 0 {
+		// Lets statements end at the end of the input
+		return tokens.Token {
+			Type: tokens.EOF,
+			Location: p.tokens[len(p.tokens)-1].Location,
+		}
 	} else {
 		panic("Unexpected EOF")
 	}
@@ -146,6 +159,45 @@ func (p *parser) peekToken() tokens.Token {
 	}
 }
 
+// span returns the span from the start of start to the end of the last token
+// consumed. Nodes are given their span once parsed, infix ones starting with
+// their left operand.
+func (p *parser) span(start location.Location) location.Location {
+	return start.Through(p.tokens[p.tokenPosition-1].Location)
+}
+
+func (p *parser) parseProgram() *nodes.Program {
+	n := &nodes.Program {
+		Statements: make([]nodes.Statement, 0),
+	}
+
+	if len(p.tokens) > 0 {
+		n.Location = location.Location {
+			File: p.tokens[0].File,
+			Line: 1,
+			Column: 1,
+		}
+	}
+
+	for p.tokenPosition < len(p.tokens) {
+		// Tokens that can't start a statement are left over from the end of
+		// the previous one, e.g. an unmatched '}'
+		_, isStatement := p.statementParsers[p.currentToken().Type]
+		_, isExpression := p.prefixParsers[p.currentToken().Type]
+		if !isStatement && !isExpression {
+			panic(fmt.Errorf("Unexpected token %q after end of statement", p.currentToken()))
+		}
+
+		n.Statements = append(n.Statements, p.parseStatement())
+	}
+
+	if len(p.tokens) > 0 {
+		n.Location = p.span(n.Location)
+	}
+
+	return n
+}
+
 func (p *parser) parseStatement() nodes.Statement {
 	statementParser, exists := p.statementParsers[p.currentToken().Type]
 	if exists {
@@ -174,6 +226,8 @@ func (p *parser) parseIf() nodes.Statement {
 		n.Alternative = p.parseStatement()
 	}
 
+	n.Location = p.span(n.Location)
+
 	return n
 }
 
@@ -196,6 +250,8 @@ func (p *parser) parseFor() nodes.Statement {
 
 	n.Loop = p.parseStatement()
 
+	n.Location = p.span(n.Location)
+
 	return n
 }
 
@@ -217,6 +273,137 @@ func (p *parser) parseAssignment() nodes.Statement {
 	n.Value = p.parseExpression(LOWEST)
 
 
+	n.Location = p.span(n.Location)
+
+	return n
+}
+
+func (p *parser) parseImport() nodes.Statement {
+	n := &nodes.Import {
+		Location: p.currentToken().Location,
+	}
+
+	p.nextToken()
+
+	p.expect(tokens.StringLiteral)
+	n.Path = p.currentToken().Literal
+
+	p.nextToken()
+
+	n.Location = p.span(n.Location)
+
+	return n
+}
+
+func (p *parser) parseConst() nodes.Statement {
+	n := &nodes.Const {
+		Location: p.currentToken().Location,
+	}
+
+	p.nextToken()
+
+	p.expect(tokens.Identifier)
+	n.Name = p.currentToken().Literal
+
+	p.nextToken()
+
+	p.consume(tokens.Assignment)
+
+	n.Value = p.parseExpression(LOWEST)
+
+	n.Location = p.span(n.Location)
+
+	return n
+}
+
+func (p *parser) parseGo() nodes.Statement {
+	n := &nodes.Go {
+		Location: p.currentToken().Location,
+	}
+
+	p.nextToken()
+
+	call, ok := p.parseExpression(LOWEST).(*nodes.Call)
+	if !ok {
+		panic(fmt.Errorf("Expected call after go at %s", n.Location))
+	}
+	n.Call = call
+
+	n.Location = p.span(n.Location)
+
+	return n
+}
+
+func (p *parser) parseSelect() nodes.Statement {
+	n := &nodes.Select {
+		Cases: make([]*nodes.SelectCase, 0),
+		Location: p.currentToken().Location,
+	}
+
+	p.nextToken()
+
+	p.consume(tokens.OpenCurlyBracket)
+
+	for p.currentToken().Type != tokens.CloseCurlyBracket {
+		if p.currentToken().Type == tokens.Default {
+			if n.Default != nil {
+				panic(fmt.Errorf("Multiple defaults in select at %s", p.currentToken().Location))
+			}
+
+			p.nextToken()
+			n.Default = p.parseStatement()
+			continue
+		}
+
+		p.expect(tokens.Case)
+		c := &nodes.SelectCase {
+			Location: p.currentToken().Location,
+		}
+
+		p.nextToken()
+
+		// The received value may be assigned like in a var statement
+		if p.currentToken().Type == tokens.Var {
+			p.nextToken()
+			c.Place = p.parseExpression(LOWEST)
+			p.consume(tokens.Assignment)
+		}
+
+		call, ok := p.parseExpression(LOWEST).(*nodes.Call)
+		if !ok {
+			panic(fmt.Errorf("Expected send or receive call in select case at %s", c.Location))
+		}
+		c.Call = call
+
+		c.Body = p.parseStatement()
+		c.Location = p.span(c.Location)
+
+		n.Cases = append(n.Cases, c)
+	}
+
+	p.nextToken()
+
+	n.Location = p.span(n.Location)
+
+	return n
+}
+
+func (p *parser) parseTest() nodes.Statement {
+	n := &nodes.Test {
+		Location: p.currentToken().Location,
+	}
+
+	p.nextToken()
+
+	p.expect(tokens.StringLiteral)
+	n.Name = p.currentToken().Literal
+
+	p.nextToken()
+
+	n.Body = p.parseStatement()
+
+	n.Location = p.span(n.Location)
+
 	return n
 }
 
@@ -234,6 +421,8 @@ func (p *parser) parseScope() nodes.Statement {
 
 	p.nextToken()
 
+	n.Location = p.span(n.Location)
+
 	return n
 }
 
@@ -263,50 +452,29 @@ func (p *parser) parseExpression(precedence Precedence) nodes.Expression {
 func (p *parser) parseIntLiteral() nodes.Expression {
 	defer p.nextToken()
 
-	literal := strings.ToLower(p.currentToken().Literal)
-
-	if strings.ContainsRune(literal, 'e') {
-		vals := strings.SplitN(p.currentToken().Literal, "e", 2)
-		coefficient, err := strconv.Atoi(vals[0])
-		if err != nil {
-			// ERROR: can't parse IntLiteral
-			panic(fmt.Errorf("Failed to parse %q into IntLiteral, %s", p.currentToken(), err))
-		}
-		exponent, err := strconv.Atoi(vals[1])
-		if err != nil {
-			// ERROR: can't parse IntLiteral
-			panic(fmt.Errorf("Failed to parse %q into IntLiteral, %s", p.currentToken(), err))
-		}
-
-		return &nodes.IntLiteral {
-			Value: coefficient * int(math.Pow10(exponent)),
-			Location: p.currentToken().Location,
-		}
-	} else {
-		val, err := strconv.Atoi(p.currentToken().Literal)
-		if err != nil {
-			// ERROR: invalid IntLiteral token
-			panic(fmt.Errorf("Failed to parse %q into IntLiteral, %s", p.currentToken(), err))
-		}
+	val, err := tokens.ParseInt(p.currentToken().Literal)
+	if err != nil {
+		// ERROR: invalid IntLiteral token
+		panic(fmt.Errorf("Failed to parse %q into IntLiteral, %s", p.currentToken(), err))
+	}
 
-		return &nodes.IntLiteral {
-			Value: val,
-			Location: p.currentToken().Location,
-		}
+	return &nodes.IntLiteral {
+		Value: val,
+		Location: p.currentToken().Location,
 	}
 }
 
 func (p *parser) parseFloatLiteral() nodes.Expression {
 	defer p.nextToken()
 
-	val, err := strconv.ParseFloat(p.currentToken().Literal, 32)
+	val, err := tokens.ParseFloat(p.currentToken().Literal)
 	if err != nil {
 		// ERROR: can't parse FloatLiteral
-			panic(fmt.Errorf("Failed to parse %q into FloatLiteral, %s", p.currentToken(), err))
+		panic(fmt.Errorf("Failed to parse %q into FloatLiteral, %s", p.currentToken(), err))
 	}
 
 	return &nodes.FloatLiteral {
-		Value: float32(val),
+		Value: val,
 		Location: p.currentToken().Location,
 	}
 }
@@ -315,11 +483,41 @@ func (p *parser) parseStringLiteral() nodes.Expression {
 	defer p.nextToken()
 
 	return &nodes.StringLiteral {
-		Value: strings.TrimPrefix(strings.TrimSuffix(p.currentToken().Literal, "\""), "\""),
+		Value: p.currentToken().Literal,
 		Location: p.currentToken().Location,
 	}
 }
 
+func (p *parser) parseInterpolation() nodes.Expression {
+	n := &nodes.Interpolation {
+		Parts: make([]nodes.Expression, 0),
+		Location: p.currentToken().Location,
+	}
+
+	for {
+		if p.currentToken().Literal != "" {
+			n.Parts = append(n.Parts, &nodes.StringLiteral {
+				Value: p.currentToken().Literal,
+				Location: p.currentToken().Location,
+			})
+		}
+
+		if p.currentToken().Type == tokens.InterpolationEnd {
+			p.nextToken()
+			n.Location = p.span(n.Location)
+			return n
+		}
+
+		p.nextToken()
+
+		n.Parts = append(n.Parts, p.parseExpression(LOWEST))
+
+		if t := p.currentToken().Type; t != tokens.InterpolationMiddle && t != tokens.InterpolationEnd {
+			panic(fmt.Errorf("Unexpected token %q, expected end of interpolated expression", p.currentToken()))
+		}
+	}
+}
+
 func (p *parser) parserBoolLiteral() nodes.Expression {
 	defer p.nextToken()
 
@@ -355,6 +553,8 @@ func (p *parser) parsePrefixOperator() nodes.Expression {
 
 	n.Operand = p.parseExpression(UNARY)
 
+	n.Location = p.span(n.Location)
+
 	return n
 }
 
@@ -371,6 +571,8 @@ func (p *parser) parseOperator(left nodes.Expression) nodes.Expression {
 
 	n.Right = p.parseExpression(opPrecendence)
 
+	n.Location = p.span(left.GetLocation())
+
 	return n
 }
 
@@ -385,17 +587,49 @@ func (p *parser) parseCall(left nodes.Expression) nodes.Expression {
 
 	if p.currentToken().Type == tokens.CloseBracket {
 		p.nextToken()
+		n.Location = p.span(left.GetLocation())
 		return n
 	}
 
 	n.Arguments = append(n.Arguments, p.parseExpression(LOWEST))
 
-	for ; p.currentToken().Type == tokens.Comma; p.nextToken() {
+	for p.currentToken().Type == tokens.Comma {
+		p.nextToken()
 		n.Arguments = append(n.Arguments, p.parseExpression(LOWEST))
 	}
 
 	p.consume(tokens.CloseBracket)
 
+	n.Location = p.span(left.GetLocation())
+
+	return n
+}
+
+func (p *parser) parseArrayLiteral() nodes.Expression {
+	n := &nodes.ArrayLiteral {
+		Elements: make([]nodes.Expression, 0),
+		Location: p.currentToken().Location,
+	}
+
+	p.nextToken()
+
+	if p.currentToken().Type == tokens.CloseSquareBracket {
+		p.nextToken()
+		n.Location = p.span(n.Location)
+		return n
+	}
+
+	n.Elements = append(n.Elements, p.parseExpression(LOWEST))
+
+	for p.currentToken().Type == tokens.Comma {
+		p.nextToken()
+		n.Elements = append(n.Elements, p.parseExpression(LOWEST))
+	}
+
+	p.consume(tokens.CloseSquareBracket)
+
+	n.Location = p.span(n.Location)
+
 	return n
 }
 
@@ -411,6 +645,26 @@ func (p *parser) parseIndex(left nodes.Expression) nodes.Expression {
 
 	p.consume(tokens.CloseSquareBracket)
 
+	n.Location = p.span(left.GetLocation())
+
+	return n
+}
+
+func (p *parser) parseMember(left nodes.Expression) nodes.Expression {
+	n := &nodes.Member {
+		Structure: left,
+		Location: p.currentToken().Location,
+	}
+
+	p.nextToken()
+
+	p.expect(tokens.Identifier)
+	n.Name = p.currentToken().Literal
+
+	p.nextToken()
+
+	n.Location = p.span(left.GetLocation())
+
 	return n
 }
 
@@ -1,3 +1,14 @@
 #! /bin/sh
 
-sed 's/\/\/.*$//g' $1 | lexer2/lexer2 | parser/parser | interpreter/interpreter
+if [ $# -eq 0 ]; then
+	echo "usage: $0 file [script arguments...]" >&2
+	exit 1
+fi
+
+# The ast is passed to the interpreter as a file so the program can read stdin
+ast=$(mktemp)
+trap 'rm -f "$ast"' EXIT
+
+lexer2/lexer2 "$1" | parser/parser | linker/linker > "$ast"
+shift
+interpreter/interpreter "$ast" "$@"
@@ -0,0 +1,10 @@
+#! /bin/sh
+
+# Runs the test blocks of every file given, exiting with status 1 if any fail
+status=0
+
+for f in "$@"; do
+	lexer2/lexer2 "$f" | parser/parser | linker/linker | interpreter/interpreter -test || status=1
+done
+
+exit $status
@@ -0,0 +1,93 @@
+package tokens
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// UnescapeString decodes the escape sequences in the body of a string
+// literal (the text between the quotes).
+// On failure the returned ints are the byte offsets into s of the start and
+// end of the invalid escape sequence, which the error quotes as written.
+func UnescapeString(s string) (string, int, int, error) {
+	// Most literals have nothing to decode
+	if strings.IndexByte(s, '\\') < 0 && utf8.ValidString(s) {
+		return s, 0, 0, nil
+	}
+
+	var b strings.Builder
+
+	for i := 0; i < len(s); {
+		if s[i] != '\\' {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			b.WriteRune(r)
+			i += size
+			continue
+		}
+
+		start := i
+		i++
+		if i >= len(s) {
+			return "", start, i, fmt.Errorf("unterminated escape sequence")
+		}
+
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+			i++
+		case 't':
+			b.WriteByte('\t')
+			i++
+		case 'r':
+			b.WriteByte('\r')
+			i++
+		case '\\':
+			b.WriteByte('\\')
+			i++
+		case '"':
+			b.WriteByte('"')
+			i++
+		case '$':
+			b.WriteByte('$')
+			i++
+		case 'x':
+			if i+3 > len(s) {
+				return "", start, len(s), fmt.Errorf("escape sequence \\x needs two hex digits")
+			}
+			val, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+			if err != nil {
+				return "", start, i+3, fmt.Errorf(`invalid escape sequence "%s"`, s[start:i+3])
+			}
+			if val > 0x7F {
+				return "", start, i+3, fmt.Errorf(`escape sequence "%s" is out of range, must be at most \x7F`, s[start:i+3])
+			}
+			b.WriteByte(byte(val))
+			i += 3
+		case 'u':
+			if i+1 >= len(s) || s[i+1] != '{' {
+				return "", start, i+1, fmt.Errorf("escape sequence \\u must be of the form \\u{...}")
+			}
+			end := strings.IndexByte(s[i+2:], '}')
+			if end < 0 {
+				return "", start, len(s), fmt.Errorf(`unterminated escape sequence "%s"`, s[start:])
+			}
+			digits := s[i+2 : i+2+end]
+			if len(digits) == 0 || len(digits) > 6 {
+				return "", start, i+3+end, fmt.Errorf(`escape sequence "%s" must have between 1 and 6 hex digits`, s[start:i+3+end])
+			}
+			val, err := strconv.ParseUint(digits, 16, 32)
+			if err != nil || !utf8.ValidRune(rune(val)) {
+				return "", start, i+3+end, fmt.Errorf(`escape sequence "%s" is not a valid unicode code point`, s[start:i+3+end])
+			}
+			b.WriteRune(rune(val))
+			i += 3 + end
+		default:
+			_, size := utf8.DecodeRuneInString(s[i:])
+			return "", start, i+size, fmt.Errorf(`invalid escape sequence "%s"`, s[start:i+size])
+		}
+	}
+
+	return b.String(), 0, 0, nil
+}
@@ -0,0 +1,96 @@
+//go:build ignore
+// +build ignore
+
+// Generates nfcTables.go from golang.org/x/text/unicode/norm, which must be
+// in GOPATH, e.g. copied from $GOROOT/src/vendor. Run from this directory:
+//
+//	GO111MODULE=off go run genNfcTables.go
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"strconv"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Hangul syllables are composed algorithmically rather than from the tables
+func isHangulSyllable(r rune) bool {
+	return r >= 0xAC00 && r <= 0xD7A3
+}
+
+func main() {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "// Code generated by genNfcTables.go from Unicode %s; DO NOT EDIT.\n\n", norm.Version)
+	fmt.Fprintf(&b, "package tokens\n\n")
+
+	fmt.Fprintf(&b, "// Canonical combining class of runes with one other than 0\n")
+	fmt.Fprintf(&b, "var combiningClasses = []combiningRange {\n")
+	for lo := rune(0); lo <= unicode.MaxRune; {
+		class := norm.NFD.PropertiesString(string(lo)).CCC()
+		hi := lo
+		for hi+1 <= unicode.MaxRune && norm.NFD.PropertiesString(string(hi+1)).CCC() == class {
+			hi++
+		}
+		if class != 0 {
+			fmt.Fprintf(&b, "{0x%04X, 0x%04X, %d},\n", lo, hi, class)
+		}
+		lo = hi+1
+	}
+	fmt.Fprintf(&b, "}\n\n")
+
+	decompositions := map[rune]string{}
+	for r := rune(0); r <= unicode.MaxRune; r++ {
+		if isHangulSyllable(r) || (r >= 0xD800 && r <= 0xDFFF) {
+			continue
+		}
+		if d := norm.NFD.String(string(r)); d != string(r) {
+			decompositions[r] = d
+		}
+	}
+
+	fmt.Fprintf(&b, "// Full canonical decomposition of runes with one\n")
+	fmt.Fprintf(&b, "var decompositions = map[rune]string {\n")
+	for r := rune(0); r <= unicode.MaxRune; r++ {
+		if d, exists := decompositions[r]; exists {
+			fmt.Fprintf(&b, "0x%04X: %s,\n", r, strconv.QuoteToASCII(d))
+		}
+	}
+	fmt.Fprintf(&b, "}\n\n")
+
+	// A primary composite composes from a rune and the mark last added to
+	// it, which is found by taking each mark off its decomposition in turn
+	fmt.Fprintf(&b, "// Primary composite of each pair of runes which compose\n")
+	fmt.Fprintf(&b, "var compositions = map[[2]rune]rune {\n")
+	for r := rune(0); r <= unicode.MaxRune; r++ {
+		d, exists := decompositions[r]
+		if !exists || norm.NFC.String(string(r)) != string(r) {
+			continue
+		}
+
+		runes := []rune(d)
+		for i := len(runes)-1; i > 0; i-- {
+			rest := string(runes[:i]) + string(runes[i+1:])
+			first := []rune(norm.NFC.String(rest))
+			if len(first) == 1 && norm.NFC.String(string(first[0])+string(runes[i])) == string(r) {
+				fmt.Fprintf(&b, "{0x%04X, 0x%04X}: 0x%04X,\n", first[0], runes[i], r)
+				break
+			}
+		}
+	}
+	fmt.Fprintf(&b, "}\n")
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		panic(err)
+	}
+
+	if err := ioutil.WriteFile("nfcTables.go", src, 0644); err != nil {
+		panic(err)
+	}
+}
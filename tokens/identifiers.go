@@ -0,0 +1,67 @@
+package tokens
+
+import (
+	"unicode"
+)
+
+// Identifiers follow the default syntax of UAX #31, a rune with the
+// ID_Start property or '_' followed by runes with the ID_Continue property,
+// and are normalized to NFC so canonically equivalent spellings name the
+// same thing. The DFA lexer matches them with a pattern, so changes here
+// must be made there too.
+
+// Keywords are spelled like identifiers but lexed as tokens of their own.
+var Keywords map[string] TokenType = map[string] TokenType {
+	"for": For,
+	"if": If,
+	"else": Else,
+	"return": Return,
+	"break": Break,
+	"continue": Continue,
+	"var": Var,
+	"const": Const,
+	"import": Import,
+	"go": Go,
+	"select": Select,
+	"case": Case,
+	"default": Default,
+	"test": Test,
+	"true": BoolLiteral,
+	"false": BoolLiteral,
+}
+
+// IsName reports whether s is lexed as an Identifier with the literal s, i.e.
+// it's spelled as an identifier in NFC and isn't a keyword.
+func IsName(s string) bool {
+	if _, isKeyword := Keywords[s]; isKeyword || s == "" || NFC(s) != s {
+		return false
+	}
+
+	for n, r := range s {
+		if (n == 0 && !IsIdentifierStart(r)) || (n > 0 && !IsIdentifierContinue(r)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// IsIdentifierStart reports whether r can start an identifier.
+func IsIdentifierStart(r rune) bool {
+	if r == '_' {
+		return true
+	}
+
+	return unicode.In(r, unicode.L, unicode.Nl, unicode.Other_ID_Start) && !isPattern(r)
+}
+
+// IsIdentifierContinue reports whether r can follow the start of an
+// identifier.
+func IsIdentifierContinue(r rune) bool {
+	return unicode.In(r, unicode.L, unicode.Nl, unicode.Other_ID_Start, unicode.Mn, unicode.Mc, unicode.Nd, unicode.Pc, unicode.Other_ID_Continue) && !isPattern(r)
+}
+
+// isPattern reports whether r is reserved for the syntax of languages.
+func isPattern(r rune) bool {
+	return unicode.In(r, unicode.Pattern_Syntax, unicode.Pattern_White_Space)
+}
@@ -0,0 +1,141 @@
+package tokens
+
+import (
+	"sort"
+)
+
+// NFC returns s in Unicode normalization form C, so canonically equivalent
+// strings, e.g. "é" written as one rune or as "e" and a combining accent, are
+// the same. It decomposes s, puts combining marks in canonical order then
+// composes them with the runes before them, as in UAX #15.
+func NFC(s string) string {
+	// Runes before U+0300 are unaffected
+	changes := false
+	for _, r := range s {
+		if r >= 0x300 {
+			changes = true
+			break
+		}
+	}
+	if !changes {
+		return s
+	}
+
+	return string(compose(reorder(decompose(s))))
+}
+
+// combiningRange is the runes from lo to hi inclusive, which have a
+// canonical combining class.
+type combiningRange struct {
+	lo, hi rune
+	class uint8
+}
+
+func combiningClass(r rune) uint8 {
+	i := sort.Search(len(combiningClasses), func(i int) bool {
+		return combiningClasses[i].hi >= r
+	})
+	if i < len(combiningClasses) && combiningClasses[i].lo <= r {
+		return combiningClasses[i].class
+	}
+
+	return 0
+}
+
+// Hangul syllables are composed from leading consonants, vowels and
+// optionally trailing consonants by arithmetic rather than tables.
+const (
+	hangulBase = 0xAC00
+	leadingBase = 0x1100
+	vowelBase = 0x1161
+	trailingBase = 0x11A7
+	leadingCount = 19
+	vowelCount = 21
+	trailingCount = 28
+	syllableCount = leadingCount * vowelCount * trailingCount
+)
+
+func decompose(s string) []rune {
+	decomposed := make([]rune, 0, len(s))
+
+	for _, r := range s {
+		if i := r - hangulBase; i >= 0 && i < syllableCount {
+			decomposed = append(decomposed, leadingBase + i / (vowelCount * trailingCount), vowelBase + i % (vowelCount * trailingCount) / trailingCount)
+			if t := i % trailingCount; t != 0 {
+				decomposed = append(decomposed, trailingBase + t)
+			}
+		} else if d, exists := decompositions[r]; exists {
+			decomposed = append(decomposed, []rune(d)...)
+		} else {
+			decomposed = append(decomposed, r)
+		}
+	}
+
+	return decomposed
+}
+
+// reorder sorts each run of combining marks by combining class, marks of the
+// same class keep their order.
+func reorder(runes []rune) []rune {
+	for start := 0; start < len(runes); start++ {
+		if combiningClass(runes[start]) == 0 {
+			continue
+		}
+
+		end := start
+		for end < len(runes) && combiningClass(runes[end]) != 0 {
+			end++
+		}
+
+		marks := runes[start:end]
+		sort.SliceStable(marks, func(i, j int) bool {
+			return combiningClass(marks[i]) < combiningClass(marks[j])
+		})
+		start = end
+	}
+
+	return runes
+}
+
+// compose replaces each rune which composes with the last starter, a rune of
+// class 0, by their composite, unless a rune between them blocks it by
+// having class 0 or one at least as high.
+func compose(runes []rune) []rune {
+	composed := runes[:0]
+	starter := -1
+	var lastClass uint8
+
+	for _, r := range runes {
+		class := combiningClass(r)
+
+		adjacent := starter == len(composed)-1
+		if starter >= 0 && (adjacent || (lastClass != 0 && lastClass < class)) {
+			if c, exists := composite(composed[starter], r); exists {
+				composed[starter] = c
+				continue
+			}
+		}
+
+		if class == 0 {
+			starter = len(composed)
+		}
+		composed = append(composed, r)
+		lastClass = class
+	}
+
+	return composed
+}
+
+func composite(a rune, b rune) (rune, bool) {
+	if l, v := a - leadingBase, b - vowelBase; l >= 0 && l < leadingCount && v >= 0 && v < vowelCount {
+		return hangulBase + (l * vowelCount + v) * trailingCount, true
+	}
+
+	if i, t := a - hangulBase, b - trailingBase; i >= 0 && i < syllableCount && i % trailingCount == 0 && t > 0 && t < trailingCount {
+		return a + t, true
+	}
+
+	c, exists := compositions[[2]rune{a, b}]
+
+	return c, exists
+}
@@ -0,0 +1,195 @@
+package tokens
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// NumberType returns the type of a number literal, FloatLiteral for a
+// decimal with a point and IntLiteral otherwise, or an error if the literal
+// is malformed or out of range.
+func NumberType(literal string) (TokenType, error) {
+	// Most literals are a few decimal digits, which can't overflow
+	if len(literal) < 19 && isDigits(literal) {
+		return IntLiteral, nil
+	}
+
+	if !hasBasePrefix(literal) && strings.ContainsRune(literal, '.') {
+		// Unsigned and without separators strconv accepts exactly the floats
+		// ParseFloat does, without building a cleaned copy
+		if strings.Trim(literal, "0123456789.eE+-") == "" && literal[0] != '+' && literal[0] != '-' {
+			if _, err := strconv.ParseFloat(literal, 64); err != nil {
+				return FloatLiteral, numberError(literal, err)
+			}
+			return FloatLiteral, nil
+		}
+
+		_, err := ParseFloat(literal)
+		return FloatLiteral, err
+	}
+
+	_, err := ParseInt(literal)
+	return IntLiteral, err
+}
+
+// ParseInt parses the literal of an IntLiteral token, either decimal or
+// hexadecimal, octal or binary with a 0x, 0o or 0b prefix, with optional _
+// separators between digits. Decimals may have an exponent, e.g. 15e3.
+func ParseInt(literal string) (int, error) {
+	if hasBasePrefix(literal) {
+		n, err := strconv.ParseInt(literal, 0, strconv.IntSize)
+		if err != nil {
+			return 0, numberError(literal, err)
+		}
+
+		return int(n), nil
+	}
+
+	mantissa, exponent, hasExponent := splitExponent(literal)
+
+	digits, ok := stripSeparators(mantissa)
+	if !ok {
+		return 0, fmt.Errorf("Malformed number literal %s", literal)
+	}
+
+	n, err := strconv.ParseInt(digits, 10, strconv.IntSize)
+	if err != nil {
+		return 0, numberError(literal, err)
+	}
+
+	if !hasExponent {
+		return int(n), nil
+	}
+
+	if strings.HasPrefix(exponent, "-") {
+		return 0, fmt.Errorf("Negative exponent in int literal %s, write it as a float, e.g. %s", literal, strings.Replace(literal, mantissa, mantissa+".0", 1))
+	}
+
+	digits, ok = stripSeparators(strings.TrimPrefix(exponent, "+"))
+	if !ok {
+		return 0, fmt.Errorf("Malformed number literal %s", literal)
+	}
+
+	e, err := strconv.Atoi(digits)
+	if err != nil {
+		return 0, fmt.Errorf("Int literal %s overflows int", literal)
+	}
+
+	// Stops at 0 so 0e999 is fine
+	value := int(n)
+	for ; e > 0 && value != 0; e-- {
+		if value > math.MaxInt / 10 {
+			return 0, fmt.Errorf("Int literal %s overflows int", literal)
+		}
+		value *= 10
+	}
+
+	return value, nil
+}
+
+// ParseFloat parses the literal of a FloatLiteral token, decimal digits with
+// a point where either side may be empty, e.g. .5 or 5., and optionally a
+// signed exponent, e.g. 1.5e-3, with _ separators between digits.
+func ParseFloat(literal string) (float64, error) {
+	mantissa, exponent, hasExponent := splitExponent(literal)
+
+	point := strings.IndexRune(mantissa, '.')
+	if point < 0 || mantissa == "." {
+		return 0, fmt.Errorf("Malformed number literal %s", literal)
+	}
+
+	// Each side of the point is checked on its own so a separator can't
+	// touch it
+	clean := ""
+	for _, part := range []string{mantissa[:point], mantissa[point+1:]} {
+		digits, ok := stripSeparators(part)
+		if !ok && part != "" {
+			return 0, fmt.Errorf("Malformed number literal %s", literal)
+		}
+		clean += digits + "."
+	}
+	clean = strings.TrimSuffix(clean, ".")
+
+	if hasExponent {
+		sign := ""
+		if strings.HasPrefix(exponent, "+") || strings.HasPrefix(exponent, "-") {
+			sign, exponent = exponent[:1], exponent[1:]
+		}
+
+		digits, ok := stripSeparators(exponent)
+		if !ok {
+			return 0, fmt.Errorf("Malformed number literal %s", literal)
+		}
+		clean += "e" + sign + digits
+	}
+
+	f, err := strconv.ParseFloat(clean, 64)
+	if err != nil {
+		return 0, numberError(literal, err)
+	}
+
+	return f, nil
+}
+
+// hasBasePrefix reports whether literal starts with 0x, 0o or 0b.
+func hasBasePrefix(literal string) bool {
+	return len(literal) > 1 && literal[0] == '0' && strings.ContainsRune("xXoObB", rune(literal[1]))
+}
+
+// splitExponent splits a decimal literal at its e, if it has one.
+func splitExponent(literal string) (string, string, bool) {
+	if i := strings.IndexAny(literal, "eE"); i >= 0 {
+		return literal[:i], literal[i+1:], true
+	}
+
+	return literal, "", false
+}
+
+// stripSeparators returns the decimal digits s without the _ separating
+// them, reporting whether s is digits with each _ between two of them.
+func stripSeparators(s string) (string, bool) {
+	if isDigits(s) {
+		return s, true
+	}
+
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if s[i] == '_' {
+			if i == 0 || i == len(s)-1 || s[i+1] == '_' {
+				return "", false
+			}
+		} else if s[i] >= '0' && s[i] <= '9' {
+			b.WriteByte(s[i])
+		} else {
+			return "", false
+		}
+	}
+
+	return b.String(), b.Len() > 0
+}
+
+// isDigits reports whether s is one or more decimal digits.
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+
+	return len(s) > 0
+}
+
+// numberError describes an error returned by strconv for literal.
+func numberError(literal string, err error) error {
+	if e, ok := err.(*strconv.NumError); ok && e.Err == strconv.ErrRange {
+		if hasBasePrefix(literal) || !strings.ContainsRune(literal, '.') {
+			return fmt.Errorf("Int literal %s overflows int", literal)
+		}
+		return fmt.Errorf("Float literal %s overflows float", literal)
+	}
+
+	return fmt.Errorf("Malformed number literal %s", literal)
+}
@@ -3,6 +3,7 @@ package tokens
 import (
 	"fmt"
 	"strings"
+	"strconv"
 
 	"../location"
 )
@@ -12,6 +13,11 @@ var tokenTypeToString map[TokenType] string = map[TokenType] string {
 	FloatLiteral: "FloatLiteral",
 	StringLiteral: "StringLiteral",
 	BoolLiteral: "BooleanLiteral",
+	InterpolationStart: "InterpolationStart",
+	InterpolationMiddle: "InterpolationMiddle",
+	InterpolationEnd: "InterpolationEnd",
+	DocComment: "DocComment",
+	Error: "Error",
 	Add: "Add",
 	Increment: "Increment",
 	Subtract: "Subtract",
@@ -29,8 +35,16 @@ var tokenTypeToString map[TokenType] string = map[TokenType] string {
 	Break: "Break",
 	Continue: "Continue",
 	Var: "Var",
+	Const: "Const",
+	Import: "Import",
+	Go: "Go",
+	Select: "Select",
+	Case: "Case",
+	Default: "Default",
+	Test: "Test",
 	Semicolon: "Semicolon",
 	Comma: "Comma",
+	Dot: "Dot",
 	OpenBracket: "OpenBracket",
 	CloseBracket: "CloseBracket",
 	OpenCurlyBracket: "OpenCurlyBracket",
@@ -58,6 +72,11 @@ const (
 	FloatLiteral
 	StringLiteral
 	BoolLiteral
+	InterpolationStart
+	InterpolationMiddle
+	InterpolationEnd
+	DocComment
+	Error
 	Add
 	Increment
 	Subtract
@@ -75,8 +94,16 @@ const (
 	Break
 	Continue
 	Var
+	Const
+	Import
+	Go
+	Select
+	Case
+	Default
+	Test
 	Semicolon
 	Comma
+	Dot
 	OpenBracket
 	CloseBracket
 	OpenCurlyBracket
@@ -91,6 +118,17 @@ func (t TokenType) String() string {
 	return tokenTypeToString[t]
 }
 
+// IsString reports whether tokens of type t carry free text, i.e. a decoded
+// piece of a string literal, the text of a doc comment or an error message.
+func (t TokenType) IsString() bool {
+	switch t {
+	case StringLiteral, InterpolationStart, InterpolationMiddle, InterpolationEnd, DocComment, Error:
+		return true
+	default:
+		return false
+	}
+}
+
 func TokenTypeFromString(s string) (TokenType, error) {
 	if t, ok := stringToTokenType[s]; ok {
 		return t, nil
@@ -104,6 +142,10 @@ type Token struct {
 	Type TokenType
 	Literal string
 	location.Location
+	// Only set in lossless mode, see Lossless
+	Leading string
+	Text string
+	Trailing string
 }
 
 func (t Token) String() string {
@@ -111,7 +153,35 @@ func (t Token) String() string {
 	// Although this kind of feels like a hack it's kind of
 	// what zero width spaces are meant for.
 	// t.Literal = strings.ReplaceAll(t.Literal, " ", string(rune(8203)))
-	return fmt.Sprintf("%s %s %s", t.Type, t.Literal, t.Location)
+
+	// String literals hold their decoded value, which may contain
+	// newlines, so they are quoted to keep the token on one line.
+	if t.Type.IsString() {
+		return fmt.Sprintf("%s %s %s", t.Type, strconv.Quote(t.Literal), t.Location.Encode())
+	}
+
+	return fmt.Sprintf("%s %s %s", t.Type, t.Literal, t.Location.Encode())
+}
+
+// LosslessString returns t as written by lexers in lossless mode, followed
+// by its leading trivia, text and trailing trivia. These are quoted with
+// spaces escaped so the fields are still separated by spaces.
+func (t Token) LosslessString() string {
+	return fmt.Sprintf("%s %s %s %s", t, quoteField(t.Leading), quoteField(t.Text), quoteField(t.Trailing))
+}
+
+func quoteField(s string) string {
+	return strings.ReplaceAll(strconv.Quote(s), " ", `\x20`)
+}
+
+// Errorf returns an Error token at l, lexers emit these for bad input and
+// carry on so every problem in a file is reported.
+func Errorf(l location.Location, format string, args ...interface{}) Token {
+	return Token {
+		Type: Error,
+		Literal: fmt.Sprintf(format, args...),
+		Location: l,
+	}
 }
 
 func TokenFromString(s string) (Token, error) {
@@ -129,12 +199,32 @@ func TokenFromString(s string) (Token, error) {
 	// Replace all zero width spaces with spaces
 	//t.Literal = strings.ReplaceAll(vals[1], string(rune(8203)), " ")
 
-	t.Literal = strings.Join(vals[1:len(vals)-3], " ")
+	// Lines in lossless mode end with quoted fields, others with a number
+	if last := vals[len(vals)-1]; len(vals) > 4 && strings.HasPrefix(last, "\"") {
+		trivia := make([]string, 3)
+		for i, v := range vals[len(vals)-3:] {
+			trivia[i], err = strconv.Unquote(v)
+			if err != nil {
+				return t, fmt.Errorf("can't parse %q into %s: %s", s, t.Type, err)
+			}
+		}
+		t.Leading, t.Text, t.Trailing = trivia[0], trivia[1], trivia[2]
+		vals = vals[:len(vals)-3]
+	}
 
-	t.Location, err = location.LocationFromString(strings.Join(vals[len(vals)-3:], " "))
+	fields, loc, err := location.SplitFields(vals)
 	if err != nil {
 		return t, err
 	}
+	t.Literal = strings.Join(fields[1:], " ")
+	t.Location = loc
+
+	if t.Type.IsString() {
+		t.Literal, err = strconv.Unquote(t.Literal)
+		if err != nil {
+			return t, fmt.Errorf("can't parse %q into %s: %s", s, t.Type, err)
+		}
+	}
 
 	return t, nil
 }
@@ -0,0 +1,103 @@
+package tokens
+
+import (
+	"strings"
+
+	"../location"
+)
+
+// Lossless gives tokens their source text and the trivia around them, the
+// white space, comments and skipped runes between tokens, so concatenating
+// the Leading, Text and Trailing of every token reproduces the source.
+// A token's trailing trivia runs up to the end of its line, the rest of the
+// trivia before the next token leads that token. A token is final once the
+// trivia after it has been read, the last one when Close makes an EOF token
+// leading with the trivia at the end of the source.
+//
+// Tokens without text or within the token before them, e.g. an Error
+// spanning an escape in a string literal, get no trivia. The spans of the
+// other tokens must follow each other in order.
+type Lossless struct {
+	// Source from offset base that hasn't been given to a token yet, a
+	// string so the text and trivia of tokens are sliced from it rather than
+	// each copied
+	source string
+	base uint
+	// End of the last token with text
+	end uint
+}
+
+func NewLossless() *Lossless {
+	return &Lossless{}
+}
+
+// Write adds source following that already written, it must be written
+// before the tokens lexed from it are added.
+func (l *Lossless) Write(p []byte) (int, error) {
+	l.source += string(p)
+
+	return len(p), nil
+}
+
+// slice returns the source from offset start to end.
+func (l *Lossless) slice(start uint, end uint) string {
+	return l.source[start-l.base:end-l.base]
+}
+
+// Add gives the tokens found[from:] their text and leading trivia, in place
+// as tokens are large to copy. found[ready:from] are the tokens held by the
+// last call, the last token with text and the tokens without text after it,
+// the first of which is given its trailing trivia. It returns the index of
+// the tokens now held, those before it are final.
+func (l *Lossless) Add(found []Token, ready int, from int) int {
+	for i := from; i < len(found); i++ {
+		t := &found[i]
+		if t.EndOffset == t.Offset || t.EndOffset <= l.end {
+			// Final unless held behind a token waiting for its trivia
+			if ready == i {
+				ready++
+			}
+			continue
+		}
+
+		t.Leading = l.trivia(found[ready:i], t.Offset)
+		t.Text = l.slice(t.Offset, t.EndOffset)
+
+		l.end = t.EndOffset
+		ready = i
+	}
+
+	return ready
+}
+
+// Close gives the held tokens their trailing trivia and returns an EOF token
+// at end, the location reached at the end of the source.
+func (l *Lossless) Close(held []Token, end location.Location) Token {
+	eof := Token {
+		Type: EOF,
+		Location: end.To(end),
+	}
+	eof.Leading = l.trivia(held, end.Offset)
+
+	return eof
+}
+
+// trivia gives the first held token its trailing trivia from the trivia up
+// to offset, and returns the rest of that trivia.
+func (l *Lossless) trivia(held []Token, offset uint) string {
+	trivia := l.slice(l.end, offset)
+
+	if len(held) > 0 {
+		if i := strings.IndexByte(trivia, '\n'); i >= 0 {
+			held[0].Trailing, trivia = trivia[:i], trivia[i:]
+		} else {
+			held[0].Trailing, trivia = trivia, ""
+		}
+	}
+
+	// The source before offset is no longer needed
+	l.source = l.source[offset-l.base:]
+	l.base = offset
+
+	return trivia
+}